@@ -0,0 +1,138 @@
+package prometheus
+
+import (
+	"hash/fnv"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const keySeparator = '\xff'
+
+// seriesKey hashes a metric name and its labels into the key a series is
+// stored under. Labels are hashed in name order so the key doesn't depend on
+// the order they arrived in.
+func seriesKey(name string, labels map[string]string) uint64 {
+	var buf [8]string
+	names := buf[:0]
+	for k := range labels {
+		names = append(names, k)
+	}
+	sortNames(names)
+
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	for _, k := range names {
+		h.Write([]byte{keySeparator})
+		h.Write([]byte(k))
+		h.Write([]byte{keySeparator})
+		h.Write([]byte(labels[k]))
+	}
+	return h.Sum64()
+}
+
+// sameSeries reports whether a and b belong to the same series, which their
+// keys alone can't tell for certain.
+func sameSeries(a, b *hekaSample) bool {
+	an, _, _ := sampleFamily(a)
+	bn, _, _ := sampleFamily(b)
+	al, bl := sampleLabels(a), sampleLabels(b)
+	if an != bn || len(al) != len(bl) {
+		return false
+	}
+	for k, v := range al {
+		if lv, ok := bl[k]; !ok || lv != v {
+			return false
+		}
+	}
+	return true
+}
+
+// sortNames sorts a handful of label names. Unlike sort.Strings it doesn't
+// force names onto the heap, which matters on the per-sample paths.
+func sortNames(names []string) {
+	for i := 1; i < len(names); i++ {
+		for j := i; j > 0 && names[j] < names[j-1]; j-- {
+			names[j], names[j-1] = names[j-1], names[j]
+		}
+	}
+}
+
+type descEntry struct {
+	desc   *prometheus.Desc
+	name   string
+	help   string
+	labels map[string]string
+	refs   int
+}
+
+func (e *descEntry) matches(name, help string, labels map[string]string) bool {
+	if e.name != name || e.help != help || len(e.labels) != len(labels) {
+		return false
+	}
+	for k, v := range labels {
+		if lv, ok := e.labels[k]; !ok || lv != v {
+			return false
+		}
+	}
+	return true
+}
+
+// descCache keeps the desc of every stored series so that a series updated
+// every few seconds doesn't build a new one each time. Entries live as long
+// as the series they were retained for.
+type descCache struct {
+	sync.Mutex
+	entries map[uint64]*descEntry
+}
+
+func newDescCache() *descCache {
+	return &descCache{entries: make(map[uint64]*descEntry)}
+}
+
+// get returns the desc of the series stored under key, building it when the
+// series isn't known yet. On the off chance two series hash to the same key
+// the desc is built but not cached.
+func (c *descCache) get(key uint64, name, help string, labels map[string]string) *prometheus.Desc {
+	c.Lock()
+	defer c.Unlock()
+
+	e, ok := c.entries[key]
+	if ok {
+		if e.matches(name, help, labels) {
+			return e.desc
+		}
+		return prometheus.NewDesc(name, help, []string{}, labels)
+	}
+
+	e = &descEntry{
+		desc:   prometheus.NewDesc(name, help, []string{}, labels),
+		name:   name,
+		help:   help,
+		labels: labels,
+	}
+	c.entries[key] = e
+	return e.desc
+}
+
+// retain marks the desc under key as used by a stored series.
+func (c *descCache) retain(key uint64) {
+	c.Lock()
+	if e, ok := c.entries[key]; ok {
+		e.refs++
+	}
+	c.Unlock()
+}
+
+// release drops a series' hold on the desc under key, evicting the desc once
+// nothing holds it.
+func (c *descCache) release(key uint64) {
+	c.Lock()
+	if e, ok := c.entries[key]; ok {
+		e.refs--
+		if e.refs <= 0 {
+			delete(c.entries, key)
+		}
+	}
+	c.Unlock()
+}
@@ -0,0 +1,139 @@
+package prometheus
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSeriesKey(t *testing.T) {
+	a := seriesKey("foo", map[string]string{"a": "1", "b": "2"})
+	if b := seriesKey("foo", map[string]string{"b": "2", "a": "1"}); a != b {
+		t.Errorf("key depends on label order")
+	}
+	for _, other := range []uint64{
+		seriesKey("bar", map[string]string{"a": "1", "b": "2"}),
+		seriesKey("foo", map[string]string{"a": "1", "b": "3"}),
+		seriesKey("foo", map[string]string{"a": "1"}),
+		seriesKey("foo", map[string]string{"a": "1b", "": "2"}),
+	} {
+		if other == a {
+			t.Errorf("different series share a key")
+		}
+	}
+}
+
+func TestDescCacheEviction(t *testing.T) {
+	p := newTestPromOut(t, nil)
+	now := time.Now()
+	ingest := func(payload string) {
+		hsamples, err := newHekaSampleScalar([]byte(payload), nil, time.Minute, now, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.ingest(hsamples)
+	}
+	ingest(`{"single": [{"name": "a", "valuetype": "gauge", "value": 1, "expires": 10}]}`)
+	ingest(`{"single": [{"name": "a", "valuetype": "gauge", "value": 2, "expires": 10}]}`)
+	ingest(`{"single": [{"name": "b", "valuetype": "gauge", "value": 1, "expires": 20}]}`)
+
+	if n := len(p.descs.entries); n != 2 {
+		t.Errorf("expected 2 cached descs, got %d", n)
+	}
+	var first *hekaSample
+	p.samples.each(func(h *hekaSample) {
+		if h.single.Name == "a" {
+			first = h
+		}
+	})
+	ingest(`{"single": [{"name": "a", "valuetype": "gauge", "value": 3, "expires": 10}]}`)
+	if h, _ := p.samples.get(first); h.desc != first.desc {
+		t.Errorf("desc not reused for an updated series")
+	}
+
+	p.sweep(now.Add(15 * time.Second))
+	if n := len(p.descs.entries); n != 1 {
+		t.Errorf("expected 1 cached desc after expiry, got %d", n)
+	}
+	p.sweep(now.Add(25 * time.Second))
+	if n := len(p.descs.entries); n != 0 {
+		t.Errorf("expected no cached descs after expiry, got %d", n)
+	}
+}
+
+var benchPayload = []byte(`
+{
+  "single": [
+    {"name": "requests", "valuetype": "counter", "help": "requests served", "value": 10,
+     "labels": {"host": "web1", "code": "200", "method": "GET"}},
+    {"name": "requests", "valuetype": "counter", "help": "requests served", "value": 1,
+     "labels": {"host": "web1", "code": "500", "method": "GET"}},
+    {"name": "inflight", "valuetype": "gauge", "help": "requests in flight", "value": 3,
+     "labels": {"host": "web1"}}
+  ],
+  "histogram": [
+    {"name": "latency", "help": "request latency", "count": 3, "sum": 1.2,
+     "labels": {"host": "web1"}, "buckets": {"0.1": 1, "1": 2, "10": 3}}
+  ]
+}`)
+
+func BenchmarkDecode(b *testing.B) {
+	now := time.Now()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := newHekaSampleScalar(benchPayload, nil, time.Minute, now, now); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkDecodeVector compares a hundred series sent as single entries
+// with the same series sent as one vector.
+func BenchmarkDecodeVector(b *testing.B) {
+	var single, values, tuples []string
+	for i := 0; i < 100; i++ {
+		single = append(single, fmt.Sprintf(
+			`{"name": "queue_depth", "valuetype": "gauge", "help": "messages queued",
+			  "value": %d, "labels": {"host": "web1", "queue": "q%d"}}`, i, i,
+		))
+		values = append(values, fmt.Sprint(i))
+		tuples = append(tuples, fmt.Sprintf(`["web1", "q%d"]`, i))
+	}
+	for name, payload := range map[string][]byte{
+		"single": []byte(`{"single": [` + strings.Join(single, ",") + `]}`),
+		"vector": []byte(`{"vector": [{"name": "queue_depth", "valuetype": "gauge",
+		  "help": "messages queued", "labelnames": ["host", "queue"],
+		  "labelvalues": [` + strings.Join(tuples, ",") + `],
+		  "values": [` + strings.Join(values, ",") + `]}]}`),
+	} {
+		b.Run(name, func(b *testing.B) {
+			now := time.Now()
+			b.SetBytes(int64(len(payload)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := newHekaSampleScalar(payload, nil, time.Minute, now, now); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkDecodeAndIngest updates the same series over and over, the way
+// filters do on every timer_event.
+func BenchmarkDecodeAndIngest(b *testing.B) {
+	p := new(PromOut)
+	if err := p.setup(p.ConfigStruct().(*PromOutConfig)); err != nil {
+		b.Fatal(err)
+	}
+	now := time.Now()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		hsamples, err := newHekaSampleScalar(benchPayload, nil, time.Minute, now, now)
+		if err != nil {
+			b.Fatal(err)
+		}
+		p.ingest(hsamples)
+	}
+}
@@ -0,0 +1,53 @@
+package prometheus
+
+import (
+	"container/heap"
+	"time"
+)
+
+// expiryHeap orders stored samples by expiry so that sweeping only ever
+// touches the samples that are due. Every sample remembers its index in the
+// heap, which lets a replacement take over the slot of the sample it replaces.
+type expiryHeap []*hekaSample
+
+func (e expiryHeap) Len() int           { return len(e) }
+func (e expiryHeap) Less(i, j int) bool { return e[i].expires.Before(e[j].expires) }
+
+func (e expiryHeap) Swap(i, j int) {
+	e[i], e[j] = e[j], e[i]
+	e[i].index = i
+	e[j].index = j
+}
+
+func (e *expiryHeap) Push(x interface{}) {
+	h := x.(*hekaSample)
+	h.index = len(*e)
+	*e = append(*e, h)
+}
+
+func (e *expiryHeap) Pop() interface{} {
+	old := *e
+	n := len(old)
+	h := old[n-1]
+	old[n-1] = nil
+	h.index = -1
+	*e = old[:n-1]
+	return h
+}
+
+// replace puts h in the slot of old and restores heap order.
+func (e *expiryHeap) replace(old, h *hekaSample) {
+	h.index = old.index
+	(*e)[h.index] = h
+	old.index = -1
+	heap.Fix(e, h.index)
+}
+
+// due pops and returns the samples that have expired by now.
+func (e *expiryHeap) due(now time.Time) []*hekaSample {
+	var expired []*hekaSample
+	for e.Len() > 0 && now.After((*e)[0].expires) {
+		expired = append(expired, heap.Pop(e).(*hekaSample))
+	}
+	return expired
+}
@@ -0,0 +1,209 @@
+package prometheus
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+)
+
+// expositionCache renders the exposition once per interval and serves the
+// same bytes, plain or gzipped, to every scrape in between. Several
+// Prometheus replicas scraping a big store then cost one render instead of
+// one each. A failed render drops the cached bytes rather than serve them
+// stale until the next render that works.
+type expositionCache struct {
+	render   func(io.Writer) error
+	interval time.Duration
+	duration prometheus.Histogram
+	failures prometheus.Counter
+	errors   func(error)
+
+	// rendering serializes renders, so scrapes arriving before the first one
+	// is cached wait for it instead of rendering alongside it.
+	rendering sync.Mutex
+
+	mu      sync.RWMutex
+	plain   []byte
+	gzipped []byte
+}
+
+func newExpositionCache(render func(io.Writer) error, interval time.Duration,
+	duration prometheus.Histogram, failures prometheus.Counter,
+	errors func(error)) *expositionCache {
+
+	return &expositionCache{
+		render:   render,
+		interval: interval,
+		duration: duration,
+		failures: failures,
+		errors:   errors,
+	}
+}
+
+// refresh renders the exposition and swaps it in for the cached one, or
+// drops the cached one when rendering fails.
+func (c *expositionCache) refresh() error {
+	c.rendering.Lock()
+	defer c.rendering.Unlock()
+	return c.refreshLocked()
+}
+
+func (c *expositionCache) refreshLocked() error {
+	start := time.Now()
+
+	var plain, gzipped bytes.Buffer
+	err := c.render(&plain)
+	if err == nil {
+		gz := gzip.NewWriter(&gzipped)
+		gz.Write(plain.Bytes())
+		err = gz.Close()
+	}
+	if err != nil {
+		c.failures.Inc()
+		c.mu.Lock()
+		c.plain, c.gzipped = nil, nil
+		c.mu.Unlock()
+		return err
+	}
+
+	c.mu.Lock()
+	c.plain, c.gzipped = plain.Bytes(), gzipped.Bytes()
+	c.mu.Unlock()
+
+	c.duration.Observe(time.Since(start).Seconds())
+	return nil
+}
+
+// run refreshes the cache every interval until stop is closed.
+func (c *expositionCache) run(stop <-chan struct{}) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.refresh(); err != nil {
+				c.errors(err)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// cached returns the cached exposition, rendering it first when there is
+// none yet.
+func (c *expositionCache) cached() (plain, gzipped []byte, err error) {
+	c.mu.RLock()
+	plain, gzipped = c.plain, c.gzipped
+	c.mu.RUnlock()
+	if plain != nil {
+		return plain, gzipped, nil
+	}
+
+	c.rendering.Lock()
+	defer c.rendering.Unlock()
+	c.mu.RLock()
+	plain, gzipped = c.plain, c.gzipped
+	c.mu.RUnlock()
+	if plain != nil {
+		return plain, gzipped, nil
+	}
+
+	if err = c.refreshLocked(); err != nil {
+		return nil, nil, err
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.plain, c.gzipped, nil
+}
+
+func (c *expositionCache) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	plain, gzipped, err := c.cached()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", string(expfmt.FmtText))
+	w.Header().Add("Vary", "Accept-Encoding")
+	if acceptsGzip(req.Header.Get("Accept-Encoding")) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(gzipped)
+		return
+	}
+	w.Write(plain)
+}
+
+// expositionHandler serves whatever render writes, gzipped when the scraper
+// accepts it. The response is already underway when render fails, so errors
+// only get logged.
+func expositionHandler(render func(io.Writer) error, errors func(error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", string(expfmt.FmtText))
+		w.Header().Add("Vary", "Accept-Encoding")
+		if !acceptsGzip(req.Header.Get("Accept-Encoding")) {
+			if err := render(w); err != nil {
+				errors(err)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		if err := render(gz); err != nil {
+			errors(err)
+		}
+		gz.Close()
+	})
+}
+
+// acceptsGzip reports whether an Accept-Encoding header admits gzip, either
+// by name or through *, with a weight above zero.
+func acceptsGzip(header string) bool {
+	gzipQ, anyQ := -1.0, -1.0
+	for _, coding := range strings.Split(header, ",") {
+		params := strings.Split(coding, ";")
+		q := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		switch strings.ToLower(strings.TrimSpace(params[0])) {
+		case "gzip", "x-gzip":
+			gzipQ = q
+		case "*":
+			anyQ = q
+		}
+	}
+	if gzipQ >= 0 {
+		return gzipQ > 0
+	}
+	return anyQ > 0
+}
+
+// writeExposition writes everything registered with the default registry in
+// the text format.
+func writeExposition(w io.Writer) error {
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		return err
+	}
+	for _, f := range families {
+		if _, err := expfmt.MetricFamilyToText(w, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package prometheus
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestExpositionCache(t *testing.T) {
+	renders := 0
+	render := func(w io.Writer) error {
+		renders++
+		_, err := io.WriteString(w, "foo 1\n")
+		return err
+	}
+	duration := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "render"})
+	failures := prometheus.NewCounter(prometheus.CounterOpts{Name: "failures"})
+	c := newExpositionCache(render, time.Hour, duration, failures, func(err error) {
+		t.Error(err)
+	})
+
+	for i := 0; i < 3; i++ {
+		rec := httptest.NewRecorder()
+		c.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+		if body := rec.Body.String(); body != "foo 1\n" {
+			t.Errorf("unexpected body %q", body)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, req)
+	if enc := rec.Header().Get("Content-Encoding"); enc != "gzip" {
+		t.Fatalf("expected gzip encoding, got %q", enc)
+	}
+	if vary := rec.Header().Get("Vary"); vary != "Accept-Encoding" {
+		t.Errorf("expected to vary on Accept-Encoding, got %q", vary)
+	}
+	gz, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := ioutil.ReadAll(gz); string(body) != "foo 1\n" {
+		t.Errorf("unexpected gzipped body %q", body)
+	}
+
+	if renders != 1 {
+		t.Errorf("expected a single render, got %d", renders)
+	}
+}
+
+func TestExpositionCacheFailure(t *testing.T) {
+	fail := false
+	render := func(w io.Writer) error {
+		if fail {
+			return errors.New("render failed")
+		}
+		_, err := io.WriteString(w, "foo 1\n")
+		return err
+	}
+	duration := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "render"})
+	failures := prometheus.NewCounter(prometheus.CounterOpts{Name: "failures"})
+	c := newExpositionCache(render, time.Hour, duration, failures, func(error) {})
+
+	if err := c.refresh(); err != nil {
+		t.Fatal(err)
+	}
+	fail = true
+	if err := c.refresh(); err == nil {
+		t.Fatal("expected the render to fail")
+	}
+	if n := counterValue(failures); n != 1 {
+		t.Errorf("expected 1 failure, got %v", n)
+	}
+
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("expected the stale exposition to be dropped, got %d %q",
+			rec.Code, rec.Body.String())
+	}
+}
+
+func TestExpositionCacheFirstRender(t *testing.T) {
+	var renders int32
+	release := make(chan struct{})
+	render := func(w io.Writer) error {
+		atomic.AddInt32(&renders, 1)
+		<-release
+		_, err := io.WriteString(w, "foo 1\n")
+		return err
+	}
+	duration := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "render"})
+	failures := prometheus.NewCounter(prometheus.CounterOpts{Name: "failures"})
+	c := newExpositionCache(render, time.Hour, duration, failures, func(error) {})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := httptest.NewRecorder()
+			c.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+			if body := rec.Body.String(); body != "foo 1\n" {
+				t.Errorf("unexpected body %q", body)
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := atomic.LoadInt32(&renders); n != 1 {
+		t.Errorf("expected concurrent first scrapes to share a render, got %d", n)
+	}
+}
+
+func TestAcceptsGzip(t *testing.T) {
+	for header, want := range map[string]bool{
+		"":                     false,
+		"gzip":                 true,
+		"gzip, deflate":        true,
+		"deflate, GZIP;q=0.5":  true,
+		"gzip;q=0":             false,
+		"gzip; q=0.0, deflate": false,
+		"*":                    true,
+		"*, gzip;q=0":          false,
+		"*;q=0":                false,
+		"identity":             false,
+		"x-gzip":               true,
+	} {
+		if got := acceptsGzip(header); got != want {
+			t.Errorf("%q: expected %v, got %v", header, want, got)
+		}
+	}
+}
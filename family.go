@@ -0,0 +1,235 @@
+package prometheus
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+)
+
+// family is what the plugin knows about all the series sharing a metric name.
+// Prometheus refuses to expose a family whose series disagree on type or help,
+// so the first series to arrive sets both until every series has expired.
+// The label names of that first series are kept for strict_labels.
+type family struct {
+	kind   string
+	help   string
+	labels []string
+	series int
+}
+
+type families map[string]*family
+
+// sampleFamily returns the name, help and type of the family h belongs to.
+func sampleFamily(h *hekaSample) (name, help, kind string) {
+	switch {
+	case h.single != nil:
+		switch h.single.valueType {
+		case prometheus.CounterValue:
+			kind = "counter"
+		case prometheus.GaugeValue:
+			kind = "gauge"
+		default:
+			kind = "untyped"
+		}
+		return h.single.Name, h.single.Help, kind
+	case h.hist != nil:
+		return h.hist.Name, h.hist.Help, "histogram"
+	case h.summ != nil:
+		return h.summ.Name, h.summ.Help, "summary"
+	}
+	return "", "", ""
+}
+
+// sampleLabels returns the labels h was sent with.
+func sampleLabels(h *hekaSample) map[string]string {
+	switch {
+	case h.single != nil:
+		return h.single.Labels
+	case h.hist != nil:
+		return h.hist.Labels
+	case h.summ != nil:
+		return h.summ.Labels
+	}
+	return nil
+}
+
+// setSampleLabels replaces the labels of h.
+func setSampleLabels(h *hekaSample, labels map[string]string) {
+	switch {
+	case h.single != nil:
+		h.single.Labels = labels
+	case h.hist != nil:
+		h.hist.Labels = labels
+	case h.summ != nil:
+		h.summ.Labels = labels
+	}
+}
+
+// checkNames refuses h when Prometheus would refuse its metric name or one
+// of its label names, a single one of those spoils the whole exposition.
+func checkNames(h *hekaSample) error {
+	name, _, _ := sampleFamily(h)
+	if !model.IsValidMetricName(model.LabelValue(name)) {
+		return fmt.Errorf("invalid metric name %q", name)
+	}
+	for l := range sampleLabels(h) {
+		if !model.LabelName(l).IsValid() || strings.HasPrefix(l, model.ReservedLabelPrefix) {
+			return fmt.Errorf("metric %q: invalid label name %q", name, l)
+		}
+	}
+	return nil
+}
+
+// addLabels adds labels to every sample that doesn't carry them already.
+func addLabels(hsamples []*hekaSample, labels map[string]string) {
+	if len(labels) == 0 {
+		return
+	}
+	for _, h := range hsamples {
+		setSampleLabels(h, mergeLabels(labels, sampleLabels(h)))
+	}
+}
+
+// mergeLabels returns base overlaid with own, own itself when base is empty.
+func mergeLabels(base, own map[string]string) map[string]string {
+	if len(base) == 0 {
+		return own
+	}
+	merged := make(map[string]string, len(base)+len(own))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range own {
+		merged[k] = v
+	}
+	return merged
+}
+
+func labelNames(labels map[string]string) []string {
+	names := make([]string, 0, len(labels))
+	for k := range labels {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// conformLabels checks labels against the label names of a schema. Labels the
+// schema doesn't know are an error, missing ones are filled with fill or are
+// an error when fill is empty. The returned map is a copy when anything was
+// filled in.
+func conformLabels(labels map[string]string, schema []string, fill string) (map[string]string, error) {
+	known := 0
+	var missing []string
+	for _, name := range schema {
+		if _, ok := labels[name]; ok {
+			known++
+		} else {
+			missing = append(missing, name)
+		}
+	}
+	if known != len(labels) {
+		return nil, fmt.Errorf(
+			"labels %v don't fit the label schema %v", labelNames(labels), schema,
+		)
+	}
+	if len(missing) == 0 {
+		return labels, nil
+	}
+	if fill == "" {
+		return nil, fmt.Errorf("missing labels %v", missing)
+	}
+
+	filled := make(map[string]string, len(schema))
+	for k, v := range labels {
+		filled[k] = v
+	}
+	for _, name := range missing {
+		filled[name] = fill
+	}
+	return filled, nil
+}
+
+// exposedSuffixes lists the suffixes of the names histograms and summaries
+// are exposed under besides their own.
+var exposedSuffixes = map[string][]string{
+	"histogram": {"_bucket", "_sum", "_count"},
+	"summary":   {"_sum", "_count"},
+}
+
+// check returns an error when h disagrees with the family already stored
+// under its name, or when its name clashes with one a stored histogram or
+// summary is exposed under, or the other way around.
+func (fs families) check(h *hekaSample) error {
+	name, help, kind := sampleFamily(h)
+	for _, suffix := range exposedSuffixes[kind] {
+		if f, ok := fs[name+suffix]; ok {
+			return fmt.Errorf(
+				"%s %q clashes with the stored %s %q", kind, name, f.kind, name+suffix,
+			)
+		}
+	}
+	// a histogram's suffixes cover a summary's
+	for _, suffix := range exposedSuffixes["histogram"] {
+		base := strings.TrimSuffix(name, suffix)
+		if base == name {
+			continue
+		}
+		if f, ok := fs[base]; ok {
+			for _, s := range exposedSuffixes[f.kind] {
+				if s == suffix {
+					return fmt.Errorf(
+						"metric %q clashes with the stored %s %q", name, f.kind, base,
+					)
+				}
+			}
+		}
+	}
+
+	f, ok := fs[name]
+	if !ok {
+		return nil
+	}
+	if f.kind != kind {
+		return fmt.Errorf(
+			"metric %q sent as %s but stored as %s", name, kind, f.kind,
+		)
+	}
+	if f.help != help {
+		return fmt.Errorf(
+			"metric %q sent with help %q but stored with %q", name, help, f.help,
+		)
+	}
+	return nil
+}
+
+// add accounts for a new series in its family, creating the family if needed.
+func (fs families) add(h *hekaSample) {
+	name, help, kind := sampleFamily(h)
+	f, ok := fs[name]
+	if !ok {
+		f = &family{
+			kind:   kind,
+			help:   help,
+			labels: labelNames(sampleLabels(h)),
+		}
+		fs[name] = f
+	}
+	f.series++
+}
+
+// remove forgets a series, dropping its family along with the last one.
+func (fs families) remove(h *hekaSample) {
+	name, _, _ := sampleFamily(h)
+	f, ok := fs[name]
+	if !ok {
+		return
+	}
+	f.series--
+	if f.series <= 0 {
+		delete(fs, name)
+	}
+}
@@ -0,0 +1,76 @@
+package prometheus
+
+import (
+	"time"
+)
+
+// haTracker deduplicates redundant producers the way Cortex does: samples
+// carry a cluster and a replica label, only the samples of the replica
+// elected for a cluster are accepted, and another replica takes over once
+// the elected one has been silent for the failover timeout. Only Run's
+// goroutine touches it.
+type haTracker struct {
+	clusterLabel string
+	replicaLabel string
+	timeout      time.Duration
+	elected      map[string]*haElection
+}
+
+// haForgetAfter is how many failover timeouts a cluster has to stay silent
+// for its election to be forgotten. Any replica is elected when it comes
+// back, which it would be anyway after a single timeout.
+const haForgetAfter = 10
+
+type haElection struct {
+	replica  string
+	lastSeen time.Time
+}
+
+func newHATracker(clusterLabel, replicaLabel string, timeout time.Duration) *haTracker {
+	return &haTracker{
+		clusterLabel: clusterLabel,
+		replicaLabel: replicaLabel,
+		timeout:      timeout,
+		elected:      make(map[string]*haElection),
+	}
+}
+
+// elect reports whether a sample of replica in cluster seen at now is
+// accepted, and whether replica just took over from another.
+func (t *haTracker) elect(cluster, replica string, now time.Time) (accepted, failover bool) {
+	e, ok := t.elected[cluster]
+	switch {
+	case !ok:
+		t.elected[cluster] = &haElection{replica, now}
+		return true, false
+	case e.replica == replica:
+		e.lastSeen = now
+		return true, false
+	case now.Sub(e.lastSeen) > t.timeout:
+		e.replica, e.lastSeen = replica, now
+		return true, true
+	}
+	return false, false
+}
+
+// prune forgets the elections of clusters that have been silent for
+// haForgetAfter failover timeouts, so clusters that come and go don't pile
+// up.
+func (t *haTracker) prune(now time.Time) {
+	for cluster, e := range t.elected {
+		if now.Sub(e.lastSeen) > haForgetAfter*t.timeout {
+			delete(t.elected, cluster)
+		}
+	}
+}
+
+// strip returns labels without the replica label.
+func (t *haTracker) strip(labels map[string]string) map[string]string {
+	stripped := make(map[string]string, len(labels))
+	for k, v := range labels {
+		if k != t.replicaLabel {
+			stripped[k] = v
+		}
+	}
+	return stripped
+}
@@ -0,0 +1,122 @@
+package prometheus
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestHADedupe(t *testing.T) {
+	config := new(PromOut).ConfigStruct().(*PromOutConfig)
+	config.HAReplicaLabel = "replica"
+	p := newTestPromOut(t, config)
+
+	now := time.Now()
+	for i, replica := range []string{"a", "b", "a", "b"} {
+		ingestPayload(t, p, now, fmt.Sprintf(
+			`{"single": [
+			  {"name": "foo", "valuetype": "gauge", "value": %d,
+			   "labels": {"cluster": "eu", "replica": "%s"}},
+			  {"name": "bar", "valuetype": "gauge", "value": %d}
+			]}`, i, replica, i,
+		))
+	}
+
+	if n := p.samples.len(); n != 2 {
+		t.Errorf("expected 2 series, got %d", n)
+	}
+	p.samples.each(func(s *hekaSample) {
+		if _, ok := s.single.Labels["replica"]; ok {
+			t.Errorf("replica label not stripped: %v", s.single.Labels)
+		}
+		if s.single.Name == "foo" && s.single.Value != 2 {
+			t.Errorf("expected the elected replica's latest value, got %v", s.single.Value)
+		}
+		if s.single.Name == "bar" && s.single.Value != 3 {
+			t.Errorf("expected samples without a replica to pass, got %v", s.single.Value)
+		}
+	})
+	if n := counterValue(p.haDropped.WithLabelValues("eu")); n != 2 {
+		t.Errorf("expected 2 dropped samples, got %v", n)
+	}
+
+	var logged []error
+	p.errLogger = func(err error) { logged = append(logged, err) }
+	ingestPayload(t, p, now, `{"single": [{"name": "baz", "valuetype": "gauge",
+	  "value": 1, "labels": {"replica": "a"}}]}`)
+	if n := counterValue(p.haNoCluster); n != 1 || len(logged) != 1 || p.samples.len() != 2 {
+		t.Errorf("expected a sample without a cluster to be dropped, got %v, %v", n, logged)
+	}
+}
+
+func TestHARegistry(t *testing.T) {
+	config := new(PromOut).ConfigStruct().(*PromOutConfig)
+	config.HAReplicaLabel = "replica"
+	config.Metrics = map[string]*MetricConfig{
+		"foo": {Type: "gauge", Labels: []string{"cluster"}},
+	}
+	p := newTestPromOut(t, config)
+
+	ingestPayload(t, p, time.Now(), `{"single": [{"name": "foo", "value": 1,
+	  "labels": {"cluster": "eu", "replica": "a"}}]}`)
+	if n := p.samples.len(); n != 1 {
+		t.Errorf("expected the replica label to pass the declared labels, %d series", n)
+	}
+}
+
+func TestHAGroup(t *testing.T) {
+	config := new(PromOut).ConfigStruct().(*PromOutConfig)
+	config.HAReplicaLabel = "replica"
+	p := newTestPromOut(t, config)
+
+	now := time.Now()
+	inventory := func(replica string, queues ...string) {
+		group := map[string]string{"instance": "mq1"}
+		labels := map[string]string{"cluster": "eu", "replica": replica}
+		groupInventory(t, p, now, group, labels, queues...)
+	}
+
+	inventory("a", "mail", "jobs")
+	// the standby replica lags behind and hasn't seen jobs yet
+	inventory("b", "mail")
+	if n := p.samples.len(); n != 2 {
+		t.Errorf("expected the standby's inventory to replace nothing, %d series left", n)
+	}
+
+	inventory("a", "mail")
+	if n := p.samples.len(); n != 1 {
+		t.Errorf("expected the elected replica's inventory to replace the group, %d series left", n)
+	}
+}
+
+func TestHAElection(t *testing.T) {
+	now := time.Now()
+	ha := newHATracker("cluster", "replica", 10*time.Second)
+	for i, step := range []struct {
+		at                 time.Duration
+		replica            string
+		accepted, failover bool
+	}{
+		{0, "a", true, false},
+		{5 * time.Second, "b", false, false},
+		{8 * time.Second, "a", true, false},
+		{15 * time.Second, "b", false, false},
+		{19 * time.Second, "b", true, true},
+		{20 * time.Second, "a", false, false},
+	} {
+		accepted, failover := ha.elect("eu", step.replica, now.Add(step.at))
+		if accepted != step.accepted || failover != step.failover {
+			t.Errorf("step %d: expected %v/%v, got %v/%v",
+				i, step.accepted, step.failover, accepted, failover)
+		}
+	}
+
+	ha.elect("us", "a", now.Add(30*time.Second))
+	ha.prune(now.Add(125 * time.Second))
+	if _, ok := ha.elected["eu"]; ok {
+		t.Error("expected the silent cluster's election to be forgotten")
+	}
+	if _, ok := ha.elected["us"]; !ok {
+		t.Error("expected the recent election to be kept")
+	}
+}
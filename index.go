@@ -0,0 +1,57 @@
+package prometheus
+
+import (
+	"strconv"
+	"strings"
+)
+
+// groupKey renders the labels of a grouping key in a canonical form.
+func groupKey(group map[string]string) string {
+	var b strings.Builder
+	for i, name := range labelNames(group) {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteByte('=')
+		b.WriteString(strconv.Quote(group[name]))
+	}
+	return b.String()
+}
+
+// seriesIndex files the keys of stored series under a name, the group or the
+// producer they belong to. Only Run's goroutine touches it.
+type seriesIndex map[string]map[uint64]bool
+
+func (g seriesIndex) add(name string, key uint64) {
+	keys, ok := g[name]
+	if !ok {
+		keys = make(map[uint64]bool)
+		g[name] = keys
+	}
+	keys[key] = true
+}
+
+func (g seriesIndex) remove(name string, key uint64) {
+	keys, ok := g[name]
+	if !ok {
+		return
+	}
+	delete(keys, key)
+	if len(keys) == 0 {
+		delete(g, name)
+	}
+}
+
+// move reindexes key from old to name, either may be empty.
+func (g seriesIndex) move(old, name string, key uint64) {
+	if old == name {
+		return
+	}
+	if old != "" {
+		g.remove(old, key)
+	}
+	if name != "" {
+		g.add(name, key)
+	}
+}
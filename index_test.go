@@ -0,0 +1,188 @@
+package prometheus
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mozilla-services/heka/pipeline"
+)
+
+// groupInventory sends the inventory of group, one queue_depth series per
+// queue, with labels shared by all entries.
+func groupInventory(t testing.TB, p *PromOut, sent time.Time, group, labels map[string]string, queues ...string) {
+	var entries []string
+	for _, q := range queues {
+		entries = append(entries, fmt.Sprintf(
+			`{"name": "queue_depth", "valuetype": "gauge", "value": 1,
+			  "labels": {"queue": "%s"}}`, q,
+		))
+	}
+	g, err := json.Marshal(group)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := json.Marshal(labels)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ingestPayload(t, p, sent, fmt.Sprintf(
+		`{"group": %s, "labels": %s, "single": [%s]}`, g, l, strings.Join(entries, ","),
+	))
+}
+
+func TestGroupReplacement(t *testing.T) {
+	p := newTestPromOut(t, nil)
+	now := time.Now()
+	inventory := func(instance string, queues ...string) {
+		group := map[string]string{"job": "rabbit", "instance": instance}
+		groupInventory(t, p, now, group, nil, queues...)
+	}
+	queues := func(instance string) []string {
+		var names []string
+		p.samples.each(func(s *hekaSample) {
+			if s.single.Labels["instance"] == instance {
+				names = append(names, s.single.Labels["queue"])
+			}
+		})
+		sort.Strings(names)
+		return names
+	}
+
+	inventory("mq1", "mail", "jobs", "logs")
+	inventory("mq2", "mail")
+	inventory("mq1", "mail", "jobs")
+	if got := fmt.Sprint(queues("mq1")); got != "[jobs mail]" {
+		t.Errorf("expected logs to be dropped from mq1, got %s", got)
+	}
+	if got := fmt.Sprint(queues("mq2")); got != "[mail]" {
+		t.Errorf("expected mq2 to be left alone, got %s", got)
+	}
+	if n := counterValue(p.groupDropped); n != 1 {
+		t.Errorf("expected 1 dropped series, got %v", n)
+	}
+	if f := p.families["queue_depth"]; f == nil || f.series != 3 {
+		t.Errorf("family not updated: %+v", f)
+	}
+
+	inventory("mq1")
+	if got := queues("mq1"); len(got) != 0 {
+		t.Errorf("expected an empty inventory to drop everything, got %v", got)
+	}
+	p.sweep(now.Add(2 * time.Minute))
+	if len(p.groups) != 0 {
+		t.Errorf("expected expiry to empty the group index, got %v", p.groups)
+	}
+}
+
+func TestGroupRejectedEntry(t *testing.T) {
+	p := newTestPromOut(t, nil)
+	now := time.Now()
+	ingestPayload(t, p, now, `{"group": {"instance": "mq1"}, "single": [
+	  {"name": "queue_depth", "valuetype": "gauge", "help": "depth", "value": 1,
+	   "labels": {"queue": "mail"}}]}`)
+	// contradicts the stored help, so the entry is rejected
+	ingestPayload(t, p, now, `{"group": {"instance": "mq1"}, "single": [
+	  {"name": "queue_depth", "valuetype": "gauge", "help": "other", "value": 2,
+	   "labels": {"queue": "mail"}}]}`)
+	if n := p.samples.len(); n != 1 {
+		t.Errorf("expected an inventory with a rejected entry to replace nothing, %d series left", n)
+	}
+}
+
+func TestGroupOutOfOrder(t *testing.T) {
+	config := new(PromOut).ConfigStruct().(*PromOutConfig)
+	config.RejectOutOfOrder = true
+	p := newTestPromOut(t, config)
+
+	now := time.Now()
+	inventory := func(sent time.Duration, queues ...string) {
+		group := map[string]string{"instance": "mq1"}
+		groupInventory(t, p, now.Add(sent), group, nil, queues...)
+	}
+
+	inventory(2*time.Second, "mail", "jobs")
+	// a retried inventory from before jobs was created
+	inventory(time.Second, "mail")
+	if n := p.samples.len(); n != 2 {
+		t.Errorf("expected a delayed inventory to replace nothing, %d series left", n)
+	}
+	if n := counterValue(p.groupDropped); n != 0 {
+		t.Errorf("expected nothing dropped, got %v", n)
+	}
+
+	inventory(3*time.Second, "mail")
+	if n := p.samples.len(); n != 1 {
+		t.Errorf("expected the next inventory to replace the group, %d series left", n)
+	}
+}
+
+func TestProducers(t *testing.T) {
+	config := new(PromOut).ConfigStruct().(*PromOutConfig)
+	config.ProducerSource = "Hostname"
+	config.ExpiryBasis = "arrival"
+	p := newTestPromOut(t, config)
+
+	recycled := make(chan *pipeline.PipelinePack, 10)
+	send := func(host, payload string) {
+		pack := pipeline.NewPipelinePack(recycled)
+		pack.Message.SetTimestamp(time.Now().UnixNano())
+		pack.Message.SetHostname(host)
+		pack.Message.SetPayload(payload)
+		hsamples, err := p.decodePack(pack)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.ingest(hsamples)
+	}
+	hosts := func() map[string]int {
+		n := make(map[string]int)
+		p.samples.each(func(s *hekaSample) { n[s.single.Labels["host"]]++ })
+		return n
+	}
+
+	for _, host := range []string{"web1", "web2"} {
+		send(host, `{"expires": 10, "labels": {"host": "`+host+`"}, "single": [
+		  {"name": "a", "valuetype": "gauge", "value": 1},
+		  {"name": "b", "valuetype": "gauge", "value": 1}
+		]}`)
+	}
+	send("relay", `{"producer": "web3", "expires": 10, "single": [
+	  {"name": "a", "valuetype": "gauge", "value": 1, "labels": {"host": "web3"}}
+	]}`)
+	if len(p.producers["web1"]) != 2 || len(p.producers["web3"]) != 1 ||
+		len(p.producers["relay"]) != 0 {
+		t.Errorf("producers not tracked: %v", p.producers)
+	}
+
+	send("web1", `{"heartbeat": 300}`)
+	p.sweep(time.Now().Add(time.Minute))
+	if n := hosts(); n["web1"] != 2 || n["web2"] != 0 || n["web3"] != 0 {
+		t.Errorf("expected only web1's series to survive, got %v", n)
+	}
+
+	send("web1", `{"goodbye": true}`)
+	if n := p.samples.len(); n != 0 {
+		t.Errorf("expected goodbye to drop web1's series, %d left", n)
+	}
+	if n := counterValue(p.goodbyeDropped); n != 2 {
+		t.Errorf("expected 2 series dropped on goodbye, got %v", n)
+	}
+	if len(p.producers) != 0 {
+		t.Errorf("expected no producers left, got %v", p.producers)
+	}
+
+	// entries riding along with a goodbye outlive it
+	send("web2", `{"single": [{"name": "a", "valuetype": "gauge", "value": 1}]}`)
+	send("web2", `{"goodbye": true, "single": [
+	  {"name": "b", "valuetype": "gauge", "value": 1}
+	]}`)
+	var names []string
+	p.samples.each(func(s *hekaSample) { names = append(names, s.single.Name) })
+	if fmt.Sprint(names) != "[b]" {
+		t.Errorf("expected only the entry sent with goodbye to be left, got %v", names)
+	}
+}
@@ -8,11 +8,31 @@ type Metrics struct {
 	Single    []*ConstMetric
 	Summary   []*ConstSummary
 	Histogram []*ConstHistogram
+	Vector    []*ConstVector
+
+	// Labels, Help, Expires and ValueType apply to every entry above that
+	// doesn't set its own, labels are merged with the entry's winning.
+	Labels    map[string]string
+	Help      string
+	Expires   int64
+	ValueType string
+
+	// Group, like a Pushgateway grouping key, labels every entry and makes
+	// the document the full inventory of the group: series of the group
+	// missing from it are dropped.
+	Group map[string]string
+
+	// Producer owns the entries. Heartbeat keeps all of its series for at
+	// least that many more seconds, Goodbye drops them.
+	Producer  string
+	Heartbeat int64
+	Goodbye   bool
 }
 
 type ConstMetric struct {
 	Value     float64
 	ValueType string
+	States    map[string]bool
 
 	Name      string
 	Labels    map[string]string
@@ -21,6 +41,19 @@ type ConstMetric struct {
 	valueType prometheus.ValueType
 }
 
+// ConstVector sends a family of single metrics at once: name, type, help and
+// label names go out once, followed by the label values and value of each
+// series, LabelValues[i] and Values[i] making up series i.
+type ConstVector struct {
+	Name        string
+	ValueType   string
+	Help        string
+	Expires     int64
+	LabelNames  []string
+	LabelValues [][]string
+	Values      []float64
+}
+
 type ConstHistogram struct {
 	Count    uint64
 	Sum      float64
@@ -1,39 +1,49 @@
-// DO NOT EDIT!
-// Code generated by ffjson <https://github.com/pquerna/ffjson>
+// Code generated by ffjson <https://github.com/pquerna/ffjson>. DO NOT EDIT.
 // source: metric.go
-// DO NOT EDIT!
 
 package prometheus
 
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	fflib "github.com/pquerna/ffjson/fflib/v1"
 )
 
-func (mj *ConstHistogram) MarshalJSON() ([]byte, error) {
+// MarshalJSON marshal bytes to json - template
+func (j *ConstHistogram) MarshalJSON() ([]byte, error) {
 	var buf fflib.Buffer
-	err := mj.MarshalJSONBuf(&buf)
+	if j == nil {
+		buf.WriteString("null")
+		return buf.Bytes(), nil
+	}
+	err := j.MarshalJSONBuf(&buf)
 	if err != nil {
 		return nil, err
 	}
 	return buf.Bytes(), nil
 }
-func (mj *ConstHistogram) MarshalJSONBuf(buf fflib.EncodingBuffer) error {
+
+// MarshalJSONBuf marshal buff to json - template
+func (j *ConstHistogram) MarshalJSONBuf(buf fflib.EncodingBuffer) error {
+	if j == nil {
+		buf.WriteString("null")
+		return nil
+	}
 	var err error
 	var obj []byte
 	_ = obj
 	_ = err
 	buf.WriteString(`{"Count":`)
-	fflib.FormatBits2(buf, uint64(mj.Count), 10, false)
+	fflib.FormatBits2(buf, uint64(j.Count), 10, false)
 	buf.WriteString(`,"Sum":`)
-	fflib.AppendFloat(buf, float64(mj.Sum), 'g', -1, 64)
-	if mj.Buckets == nil {
+	fflib.AppendFloat(buf, float64(j.Sum), 'g', -1, 64)
+	if j.Buckets == nil {
 		buf.WriteString(`,"Buckets":null`)
 	} else {
 		buf.WriteString(`,"Buckets":{ `)
-		for key, value := range mj.Buckets {
+		for key, value := range j.Buckets {
 			fflib.WriteJsonString(buf, key)
 			buf.WriteString(`:`)
 			fflib.FormatBits2(buf, uint64(value), 10, false)
@@ -43,12 +53,12 @@ func (mj *ConstHistogram) MarshalJSONBuf(buf fflib.EncodingBuffer) error {
 		buf.WriteByte('}')
 	}
 	buf.WriteString(`,"Name":`)
-	fflib.WriteJsonString(buf, string(mj.Name))
-	if mj.Labels == nil {
+	fflib.WriteJsonString(buf, string(j.Name))
+	if j.Labels == nil {
 		buf.WriteString(`,"Labels":null`)
 	} else {
 		buf.WriteString(`,"Labels":{ `)
-		for key, value := range mj.Labels {
+		for key, value := range j.Labels {
 			fflib.WriteJsonString(buf, key)
 			buf.WriteString(`:`)
 			fflib.WriteJsonString(buf, string(value))
@@ -58,54 +68,56 @@ func (mj *ConstHistogram) MarshalJSONBuf(buf fflib.EncodingBuffer) error {
 		buf.WriteByte('}')
 	}
 	buf.WriteString(`,"Help":`)
-	fflib.WriteJsonString(buf, string(mj.Help))
+	fflib.WriteJsonString(buf, string(j.Help))
 	buf.WriteString(`,"Expires":`)
-	fflib.FormatBits2(buf, uint64(mj.Expires), 10, mj.Expires < 0)
+	fflib.FormatBits2(buf, uint64(j.Expires), 10, j.Expires < 0)
 	buf.WriteByte('}')
 	return nil
 }
 
 const (
-	ffj_t_ConstHistogrambase = iota
-	ffj_t_ConstHistogramno_such_key
+	ffjtConstHistogrambase = iota
+	ffjtConstHistogramnosuchkey
 
-	ffj_t_ConstHistogram_Count
+	ffjtConstHistogramCount
 
-	ffj_t_ConstHistogram_Sum
+	ffjtConstHistogramSum
 
-	ffj_t_ConstHistogram_Buckets
+	ffjtConstHistogramBuckets
 
-	ffj_t_ConstHistogram_Name
+	ffjtConstHistogramName
 
-	ffj_t_ConstHistogram_Labels
+	ffjtConstHistogramLabels
 
-	ffj_t_ConstHistogram_Help
+	ffjtConstHistogramHelp
 
-	ffj_t_ConstHistogram_Expires
+	ffjtConstHistogramExpires
 )
 
-var ffj_key_ConstHistogram_Count = []byte("Count")
+var ffjKeyConstHistogramCount = []byte("Count")
 
-var ffj_key_ConstHistogram_Sum = []byte("Sum")
+var ffjKeyConstHistogramSum = []byte("Sum")
 
-var ffj_key_ConstHistogram_Buckets = []byte("Buckets")
+var ffjKeyConstHistogramBuckets = []byte("Buckets")
 
-var ffj_key_ConstHistogram_Name = []byte("Name")
+var ffjKeyConstHistogramName = []byte("Name")
 
-var ffj_key_ConstHistogram_Labels = []byte("Labels")
+var ffjKeyConstHistogramLabels = []byte("Labels")
 
-var ffj_key_ConstHistogram_Help = []byte("Help")
+var ffjKeyConstHistogramHelp = []byte("Help")
 
-var ffj_key_ConstHistogram_Expires = []byte("Expires")
+var ffjKeyConstHistogramExpires = []byte("Expires")
 
-func (uj *ConstHistogram) UnmarshalJSON(input []byte) error {
+// UnmarshalJSON umarshall json - template of ffjson
+func (j *ConstHistogram) UnmarshalJSON(input []byte) error {
 	fs := fflib.NewFFLexer(input)
-	return uj.UnmarshalJSONFFLexer(fs, fflib.FFParse_map_start)
+	return j.UnmarshalJSONFFLexer(fs, fflib.FFParse_map_start)
 }
 
-func (uj *ConstHistogram) UnmarshalJSONFFLexer(fs *fflib.FFLexer, state fflib.FFParseState) error {
-	var err error = nil
-	currentKey := ffj_t_ConstHistogrambase
+// UnmarshalJSONFFLexer fast json unmarshall - template ffjson
+func (j *ConstHistogram) UnmarshalJSONFFLexer(fs *fflib.FFLexer, state fflib.FFParseState) error {
+	var err error
+	currentKey := ffjtConstHistogrambase
 	_ = currentKey
 	tok := fflib.FFTok_init
 	wantedTok := fflib.FFTok_init
@@ -151,7 +163,7 @@ mainparse:
 			kn := fs.Output.Bytes()
 			if len(kn) <= 0 {
 				// "" case. hrm.
-				currentKey = ffj_t_ConstHistogramno_such_key
+				currentKey = ffjtConstHistogramnosuchkey
 				state = fflib.FFParse_want_colon
 				goto mainparse
 			} else {
@@ -159,105 +171,105 @@ mainparse:
 
 				case 'B':
 
-					if bytes.Equal(ffj_key_ConstHistogram_Buckets, kn) {
-						currentKey = ffj_t_ConstHistogram_Buckets
+					if bytes.Equal(ffjKeyConstHistogramBuckets, kn) {
+						currentKey = ffjtConstHistogramBuckets
 						state = fflib.FFParse_want_colon
 						goto mainparse
 					}
 
 				case 'C':
 
-					if bytes.Equal(ffj_key_ConstHistogram_Count, kn) {
-						currentKey = ffj_t_ConstHistogram_Count
+					if bytes.Equal(ffjKeyConstHistogramCount, kn) {
+						currentKey = ffjtConstHistogramCount
 						state = fflib.FFParse_want_colon
 						goto mainparse
 					}
 
 				case 'E':
 
-					if bytes.Equal(ffj_key_ConstHistogram_Expires, kn) {
-						currentKey = ffj_t_ConstHistogram_Expires
+					if bytes.Equal(ffjKeyConstHistogramExpires, kn) {
+						currentKey = ffjtConstHistogramExpires
 						state = fflib.FFParse_want_colon
 						goto mainparse
 					}
 
 				case 'H':
 
-					if bytes.Equal(ffj_key_ConstHistogram_Help, kn) {
-						currentKey = ffj_t_ConstHistogram_Help
+					if bytes.Equal(ffjKeyConstHistogramHelp, kn) {
+						currentKey = ffjtConstHistogramHelp
 						state = fflib.FFParse_want_colon
 						goto mainparse
 					}
 
 				case 'L':
 
-					if bytes.Equal(ffj_key_ConstHistogram_Labels, kn) {
-						currentKey = ffj_t_ConstHistogram_Labels
+					if bytes.Equal(ffjKeyConstHistogramLabels, kn) {
+						currentKey = ffjtConstHistogramLabels
 						state = fflib.FFParse_want_colon
 						goto mainparse
 					}
 
 				case 'N':
 
-					if bytes.Equal(ffj_key_ConstHistogram_Name, kn) {
-						currentKey = ffj_t_ConstHistogram_Name
+					if bytes.Equal(ffjKeyConstHistogramName, kn) {
+						currentKey = ffjtConstHistogramName
 						state = fflib.FFParse_want_colon
 						goto mainparse
 					}
 
 				case 'S':
 
-					if bytes.Equal(ffj_key_ConstHistogram_Sum, kn) {
-						currentKey = ffj_t_ConstHistogram_Sum
+					if bytes.Equal(ffjKeyConstHistogramSum, kn) {
+						currentKey = ffjtConstHistogramSum
 						state = fflib.FFParse_want_colon
 						goto mainparse
 					}
 
 				}
 
-				if fflib.EqualFoldRight(ffj_key_ConstHistogram_Expires, kn) {
-					currentKey = ffj_t_ConstHistogram_Expires
+				if fflib.EqualFoldRight(ffjKeyConstHistogramExpires, kn) {
+					currentKey = ffjtConstHistogramExpires
 					state = fflib.FFParse_want_colon
 					goto mainparse
 				}
 
-				if fflib.SimpleLetterEqualFold(ffj_key_ConstHistogram_Help, kn) {
-					currentKey = ffj_t_ConstHistogram_Help
+				if fflib.SimpleLetterEqualFold(ffjKeyConstHistogramHelp, kn) {
+					currentKey = ffjtConstHistogramHelp
 					state = fflib.FFParse_want_colon
 					goto mainparse
 				}
 
-				if fflib.EqualFoldRight(ffj_key_ConstHistogram_Labels, kn) {
-					currentKey = ffj_t_ConstHistogram_Labels
+				if fflib.EqualFoldRight(ffjKeyConstHistogramLabels, kn) {
+					currentKey = ffjtConstHistogramLabels
 					state = fflib.FFParse_want_colon
 					goto mainparse
 				}
 
-				if fflib.SimpleLetterEqualFold(ffj_key_ConstHistogram_Name, kn) {
-					currentKey = ffj_t_ConstHistogram_Name
+				if fflib.SimpleLetterEqualFold(ffjKeyConstHistogramName, kn) {
+					currentKey = ffjtConstHistogramName
 					state = fflib.FFParse_want_colon
 					goto mainparse
 				}
 
-				if fflib.EqualFoldRight(ffj_key_ConstHistogram_Buckets, kn) {
-					currentKey = ffj_t_ConstHistogram_Buckets
+				if fflib.EqualFoldRight(ffjKeyConstHistogramBuckets, kn) {
+					currentKey = ffjtConstHistogramBuckets
 					state = fflib.FFParse_want_colon
 					goto mainparse
 				}
 
-				if fflib.EqualFoldRight(ffj_key_ConstHistogram_Sum, kn) {
-					currentKey = ffj_t_ConstHistogram_Sum
+				if fflib.EqualFoldRight(ffjKeyConstHistogramSum, kn) {
+					currentKey = ffjtConstHistogramSum
 					state = fflib.FFParse_want_colon
 					goto mainparse
 				}
 
-				if fflib.SimpleLetterEqualFold(ffj_key_ConstHistogram_Count, kn) {
-					currentKey = ffj_t_ConstHistogram_Count
+				if fflib.SimpleLetterEqualFold(ffjKeyConstHistogramCount, kn) {
+					currentKey = ffjtConstHistogramCount
 					state = fflib.FFParse_want_colon
 					goto mainparse
 				}
 
-				currentKey = ffj_t_ConstHistogramno_such_key
+				currentKey = ffjtConstHistogramnosuchkey
 				state = fflib.FFParse_want_colon
 				goto mainparse
 			}
@@ -274,28 +286,28 @@ mainparse:
 			if tok == fflib.FFTok_left_brace || tok == fflib.FFTok_left_bracket || tok == fflib.FFTok_integer || tok == fflib.FFTok_double || tok == fflib.FFTok_string || tok == fflib.FFTok_bool || tok == fflib.FFTok_null {
 				switch currentKey {
 
-				case ffj_t_ConstHistogram_Count:
+				case ffjtConstHistogramCount:
 					goto handle_Count
 
-				case ffj_t_ConstHistogram_Sum:
+				case ffjtConstHistogramSum:
 					goto handle_Sum
 
-				case ffj_t_ConstHistogram_Buckets:
+				case ffjtConstHistogramBuckets:
 					goto handle_Buckets
 
-				case ffj_t_ConstHistogram_Name:
+				case ffjtConstHistogramName:
 					goto handle_Name
 
-				case ffj_t_ConstHistogram_Labels:
+				case ffjtConstHistogramLabels:
 					goto handle_Labels
 
-				case ffj_t_ConstHistogram_Help:
+				case ffjtConstHistogramHelp:
 					goto handle_Help
 
-				case ffj_t_ConstHistogram_Expires:
+				case ffjtConstHistogramExpires:
 					goto handle_Expires
 
-				case ffj_t_ConstHistogramno_such_key:
+				case ffjtConstHistogramnosuchkey:
 					err = fs.SkipField(tok)
 					if err != nil {
 						return fs.WrapErr(err)
@@ -311,7 +323,7 @@ mainparse:
 
 handle_Count:
 
-	/* handler: uj.Count type=uint64 kind=uint64 */
+	/* handler: j.Count type=uint64 kind=uint64 quoted=false*/
 
 	{
 		if tok != fflib.FFTok_integer && tok != fflib.FFTok_null {
@@ -331,7 +343,7 @@ handle_Count:
 				return fs.WrapErr(err)
 			}
 
-			uj.Count = uint64(tval)
+			j.Count = uint64(tval)
 
 		}
 	}
@@ -341,7 +353,7 @@ handle_Count:
 
 handle_Sum:
 
-	/* handler: uj.Sum type=float64 kind=float64 */
+	/* handler: j.Sum type=float64 kind=float64 quoted=false*/
 
 	{
 		if tok != fflib.FFTok_double && tok != fflib.FFTok_integer && tok != fflib.FFTok_null {
@@ -361,7 +373,7 @@ handle_Sum:
 				return fs.WrapErr(err)
 			}
 
-			uj.Sum = float64(tval)
+			j.Sum = float64(tval)
 
 		}
 	}
@@ -371,18 +383,107 @@ handle_Sum:
 
 handle_Buckets:
 
-	/* handler: uj.Buckets type=map[string]uint64 kind=map */
+	/* handler: j.Buckets type=map[string]uint64 kind=map quoted=false*/
 
 	{
-		/* Falling back. type=map[string]uint64 kind=map */
-		tbuf, err := fs.CaptureField(tok)
-		if err != nil {
-			return fs.WrapErr(err)
+
+		{
+			if tok != fflib.FFTok_left_bracket && tok != fflib.FFTok_null {
+				return fs.WrapErr(fmt.Errorf("cannot unmarshal %s into Go value for ", tok))
+			}
 		}
 
-		err = json.Unmarshal(tbuf, &uj.Buckets)
-		if err != nil {
-			return fs.WrapErr(err)
+		if tok == fflib.FFTok_null {
+			j.Buckets = nil
+		} else {
+
+			j.Buckets = make(map[string]uint64, 0)
+
+			wantVal := true
+
+			for {
+
+				var k string
+
+				var tmpJBuckets uint64
+
+				tok = fs.Scan()
+				if tok == fflib.FFTok_error {
+					goto tokerror
+				}
+				if tok == fflib.FFTok_right_bracket {
+					break
+				}
+
+				if tok == fflib.FFTok_comma {
+					if wantVal == true {
+						// TODO(pquerna): this isn't an ideal error message, this handles
+						// things like [,,,] as an array value.
+						return fs.WrapErr(fmt.Errorf("wanted value token, but got token: %v", tok))
+					}
+					continue
+				} else {
+					wantVal = true
+				}
+
+				/* handler: k type=string kind=string quoted=false*/
+
+				{
+
+					{
+						if tok != fflib.FFTok_string && tok != fflib.FFTok_null {
+							return fs.WrapErr(fmt.Errorf("cannot unmarshal %s into Go value for string", tok))
+						}
+					}
+
+					if tok == fflib.FFTok_null {
+
+					} else {
+
+						outBuf := fs.Output.Bytes()
+
+						k = string(string(outBuf))
+
+					}
+				}
+
+				// Expect ':' after key
+				tok = fs.Scan()
+				if tok != fflib.FFTok_colon {
+					return fs.WrapErr(fmt.Errorf("wanted colon token, but got token: %v", tok))
+				}
+
+				tok = fs.Scan()
+				/* handler: tmpJBuckets type=uint64 kind=uint64 quoted=false*/
+
+				{
+					if tok != fflib.FFTok_integer && tok != fflib.FFTok_null {
+						return fs.WrapErr(fmt.Errorf("cannot unmarshal %s into Go value for uint64", tok))
+					}
+				}
+
+				{
+
+					if tok == fflib.FFTok_null {
+
+					} else {
+
+						tval, err := fflib.ParseUint(fs.Output.Bytes(), 10, 64)
+
+						if err != nil {
+							return fs.WrapErr(err)
+						}
+
+						tmpJBuckets = uint64(tval)
+
+					}
+				}
+
+				j.Buckets[k] = tmpJBuckets
+
+				wantVal = false
+			}
+
 		}
 	}
 
@@ -391,7 +492,7 @@ handle_Buckets:
 
 handle_Name:
 
-	/* handler: uj.Name type=string kind=string */
+	/* handler: j.Name type=string kind=string quoted=false*/
 
 	{
 
@@ -405,7 +506,9 @@ handle_Name:
 
 		} else {
 
-			uj.Name = string(fs.Output.String())
+			outBuf := fs.Output.Bytes()
+
+			j.Name = string(string(outBuf))
 
 		}
 	}
@@ -415,18 +518,103 @@ handle_Name:
 
 handle_Labels:
 
-	/* handler: uj.Labels type=map[string]string kind=map */
+	/* handler: j.Labels type=map[string]string kind=map quoted=false*/
 
 	{
-		/* Falling back. type=map[string]string kind=map */
-		tbuf, err := fs.CaptureField(tok)
-		if err != nil {
-			return fs.WrapErr(err)
+
+		{
+			if tok != fflib.FFTok_left_bracket && tok != fflib.FFTok_null {
+				return fs.WrapErr(fmt.Errorf("cannot unmarshal %s into Go value for ", tok))
+			}
 		}
 
-		err = json.Unmarshal(tbuf, &uj.Labels)
-		if err != nil {
-			return fs.WrapErr(err)
+		if tok == fflib.FFTok_null {
+			j.Labels = nil
+		} else {
+
+			j.Labels = make(map[string]string, 0)
+
+			wantVal := true
+
+			for {
+
+				var k string
+
+				var tmpJLabels string
+
+				tok = fs.Scan()
+				if tok == fflib.FFTok_error {
+					goto tokerror
+				}
+				if tok == fflib.FFTok_right_bracket {
+					break
+				}
+
+				if tok == fflib.FFTok_comma {
+					if wantVal == true {
+						// TODO(pquerna): this isn't an ideal error message, this handles
+						// things like [,,,] as an array value.
+						return fs.WrapErr(fmt.Errorf("wanted value token, but got token: %v", tok))
+					}
+					continue
+				} else {
+					wantVal = true
+				}
+
+				/* handler: k type=string kind=string quoted=false*/
+
+				{
+
+					{
+						if tok != fflib.FFTok_string && tok != fflib.FFTok_null {
+							return fs.WrapErr(fmt.Errorf("cannot unmarshal %s into Go value for string", tok))
+						}
+					}
+
+					if tok == fflib.FFTok_null {
+
+					} else {
+
+						outBuf := fs.Output.Bytes()
+
+						k = string(string(outBuf))
+
+					}
+				}
+
+				// Expect ':' after key
+				tok = fs.Scan()
+				if tok != fflib.FFTok_colon {
+					return fs.WrapErr(fmt.Errorf("wanted colon token, but got token: %v", tok))
+				}
+
+				tok = fs.Scan()
+				/* handler: tmpJLabels type=string kind=string quoted=false*/
+
+				{
+
+					{
+						if tok != fflib.FFTok_string && tok != fflib.FFTok_null {
+							return fs.WrapErr(fmt.Errorf("cannot unmarshal %s into Go value for string", tok))
+						}
+					}
+
+					if tok == fflib.FFTok_null {
+
+					} else {
+
+						outBuf := fs.Output.Bytes()
+
+						tmpJLabels = string(string(outBuf))
+
+					}
+				}
+
+				j.Labels[k] = tmpJLabels
+
+				wantVal = false
+			}
+
 		}
 	}
 
@@ -435,7 +623,7 @@ handle_Labels:
 
 handle_Help:
 
-	/* handler: uj.Help type=string kind=string */
+	/* handler: j.Help type=string kind=string quoted=false*/
 
 	{
 
@@ -449,7 +637,9 @@ handle_Help:
 
 		} else {
 
-			uj.Help = string(fs.Output.String())
+			outBuf := fs.Output.Bytes()
+
+			j.Help = string(string(outBuf))
 
 		}
 	}
@@ -459,7 +649,7 @@ handle_Help:
 
 handle_Expires:
 
-	/* handler: uj.Expires type=int64 kind=int64 */
+	/* handler: j.Expires type=int64 kind=int64 quoted=false*/
 
 	{
 		if tok != fflib.FFTok_integer && tok != fflib.FFTok_null {
@@ -479,7 +669,7 @@ handle_Expires:
 				return fs.WrapErr(err)
 			}
 
-			uj.Expires = int64(tval)
+			j.Expires = int64(tval)
 
 		}
 	}
@@ -501,33 +691,62 @@ tokerror:
 	}
 	panic("ffjson-generated: unreachable, please report bug.")
 done:
+
 	return nil
 }
 
-func (mj *ConstMetric) MarshalJSON() ([]byte, error) {
+// MarshalJSON marshal bytes to json - template
+func (j *ConstMetric) MarshalJSON() ([]byte, error) {
 	var buf fflib.Buffer
-	err := mj.MarshalJSONBuf(&buf)
+	if j == nil {
+		buf.WriteString("null")
+		return buf.Bytes(), nil
+	}
+	err := j.MarshalJSONBuf(&buf)
 	if err != nil {
 		return nil, err
 	}
 	return buf.Bytes(), nil
 }
-func (mj *ConstMetric) MarshalJSONBuf(buf fflib.EncodingBuffer) error {
+
+// MarshalJSONBuf marshal buff to json - template
+func (j *ConstMetric) MarshalJSONBuf(buf fflib.EncodingBuffer) error {
+	if j == nil {
+		buf.WriteString("null")
+		return nil
+	}
 	var err error
 	var obj []byte
 	_ = obj
 	_ = err
 	buf.WriteString(`{"Value":`)
-	fflib.AppendFloat(buf, float64(mj.Value), 'g', -1, 64)
+	fflib.AppendFloat(buf, float64(j.Value), 'g', -1, 64)
 	buf.WriteString(`,"ValueType":`)
-	fflib.WriteJsonString(buf, string(mj.ValueType))
+	fflib.WriteJsonString(buf, string(j.ValueType))
+	if j.States == nil {
+		buf.WriteString(`,"States":null`)
+	} else {
+		buf.WriteString(`,"States":{ `)
+		for key, value := range j.States {
+			fflib.WriteJsonString(buf, key)
+			buf.WriteString(`:`)
+			if value {
+				buf.WriteString(`true`)
+			} else {
+				buf.WriteString(`false`)
+			}
+			buf.WriteByte(',')
+		}
+		buf.Rewind(1)
+		buf.WriteByte('}')
+	}
 	buf.WriteString(`,"Name":`)
-	fflib.WriteJsonString(buf, string(mj.Name))
-	if mj.Labels == nil {
+	fflib.WriteJsonString(buf, string(j.Name))
+	if j.Labels == nil {
 		buf.WriteString(`,"Labels":null`)
 	} else {
 		buf.WriteString(`,"Labels":{ `)
-		for key, value := range mj.Labels {
+		for key, value := range j.Labels {
 			fflib.WriteJsonString(buf, key)
 			buf.WriteString(`:`)
 			fflib.WriteJsonString(buf, string(value))
@@ -537,50 +756,56 @@ func (mj *ConstMetric) MarshalJSONBuf(buf fflib.EncodingBuffer) error {
 		buf.WriteByte('}')
 	}
 	buf.WriteString(`,"Help":`)
-	fflib.WriteJsonString(buf, string(mj.Help))
+	fflib.WriteJsonString(buf, string(j.Help))
 	buf.WriteString(`,"Expires":`)
-	fflib.FormatBits2(buf, uint64(mj.Expires), 10, mj.Expires < 0)
+	fflib.FormatBits2(buf, uint64(j.Expires), 10, j.Expires < 0)
 	buf.WriteByte('}')
 	return nil
 }
 
 const (
-	ffj_t_ConstMetricbase = iota
-	ffj_t_ConstMetricno_such_key
+	ffjtConstMetricbase = iota
+	ffjtConstMetricnosuchkey
+
+	ffjtConstMetricValue
 
-	ffj_t_ConstMetric_Value
+	ffjtConstMetricValueType
 
-	ffj_t_ConstMetric_ValueType
+	ffjtConstMetricStates
 
-	ffj_t_ConstMetric_Name
+	ffjtConstMetricName
 
-	ffj_t_ConstMetric_Labels
+	ffjtConstMetricLabels
 
-	ffj_t_ConstMetric_Help
+	ffjtConstMetricHelp
 
-	ffj_t_ConstMetric_Expires
+	ffjtConstMetricExpires
 )
 
-var ffj_key_ConstMetric_Value = []byte("Value")
+var ffjKeyConstMetricValue = []byte("Value")
 
-var ffj_key_ConstMetric_ValueType = []byte("ValueType")
+var ffjKeyConstMetricValueType = []byte("ValueType")
 
-var ffj_key_ConstMetric_Name = []byte("Name")
+var ffjKeyConstMetricStates = []byte("States")
 
-var ffj_key_ConstMetric_Labels = []byte("Labels")
+var ffjKeyConstMetricName = []byte("Name")
 
-var ffj_key_ConstMetric_Help = []byte("Help")
+var ffjKeyConstMetricLabels = []byte("Labels")
 
-var ffj_key_ConstMetric_Expires = []byte("Expires")
+var ffjKeyConstMetricHelp = []byte("Help")
 
-func (uj *ConstMetric) UnmarshalJSON(input []byte) error {
+var ffjKeyConstMetricExpires = []byte("Expires")
+
+// UnmarshalJSON umarshall json - template of ffjson
+func (j *ConstMetric) UnmarshalJSON(input []byte) error {
 	fs := fflib.NewFFLexer(input)
-	return uj.UnmarshalJSONFFLexer(fs, fflib.FFParse_map_start)
+	return j.UnmarshalJSONFFLexer(fs, fflib.FFParse_map_start)
 }
 
-func (uj *ConstMetric) UnmarshalJSONFFLexer(fs *fflib.FFLexer, state fflib.FFParseState) error {
-	var err error = nil
-	currentKey := ffj_t_ConstMetricbase
+// UnmarshalJSONFFLexer fast json unmarshall - template ffjson
+func (j *ConstMetric) UnmarshalJSONFFLexer(fs *fflib.FFLexer, state fflib.FFParseState) error {
+	var err error
+	currentKey := ffjtConstMetricbase
 	_ = currentKey
 	tok := fflib.FFTok_init
 	wantedTok := fflib.FFTok_init
@@ -626,7 +851,7 @@ mainparse:
 			kn := fs.Output.Bytes()
 			if len(kn) <= 0 {
 				// "" case. hrm.
-				currentKey = ffj_t_ConstMetricno_such_key
+				currentKey = ffjtConstMetricnosuchkey
 				state = fflib.FFParse_want_colon
 				goto mainparse
 			} else {
@@ -634,88 +859,102 @@ mainparse:
 
 				case 'E':
 
-					if bytes.Equal(ffj_key_ConstMetric_Expires, kn) {
-						currentKey = ffj_t_ConstMetric_Expires
+					if bytes.Equal(ffjKeyConstMetricExpires, kn) {
+						currentKey = ffjtConstMetricExpires
 						state = fflib.FFParse_want_colon
 						goto mainparse
 					}
 
 				case 'H':
 
-					if bytes.Equal(ffj_key_ConstMetric_Help, kn) {
-						currentKey = ffj_t_ConstMetric_Help
+					if bytes.Equal(ffjKeyConstMetricHelp, kn) {
+						currentKey = ffjtConstMetricHelp
 						state = fflib.FFParse_want_colon
 						goto mainparse
 					}
 
 				case 'L':
 
-					if bytes.Equal(ffj_key_ConstMetric_Labels, kn) {
-						currentKey = ffj_t_ConstMetric_Labels
+					if bytes.Equal(ffjKeyConstMetricLabels, kn) {
+						currentKey = ffjtConstMetricLabels
 						state = fflib.FFParse_want_colon
 						goto mainparse
 					}
 
 				case 'N':
 
-					if bytes.Equal(ffj_key_ConstMetric_Name, kn) {
-						currentKey = ffj_t_ConstMetric_Name
+					if bytes.Equal(ffjKeyConstMetricName, kn) {
+						currentKey = ffjtConstMetricName
+						state = fflib.FFParse_want_colon
+						goto mainparse
+					}
+
+				case 'S':
+
+					if bytes.Equal(ffjKeyConstMetricStates, kn) {
+						currentKey = ffjtConstMetricStates
 						state = fflib.FFParse_want_colon
 						goto mainparse
 					}
 
 				case 'V':
 
-					if bytes.Equal(ffj_key_ConstMetric_Value, kn) {
-						currentKey = ffj_t_ConstMetric_Value
+					if bytes.Equal(ffjKeyConstMetricValue, kn) {
+						currentKey = ffjtConstMetricValue
 						state = fflib.FFParse_want_colon
 						goto mainparse
 
-					} else if bytes.Equal(ffj_key_ConstMetric_ValueType, kn) {
-						currentKey = ffj_t_ConstMetric_ValueType
+					} else if bytes.Equal(ffjKeyConstMetricValueType, kn) {
+						currentKey = ffjtConstMetricValueType
 						state = fflib.FFParse_want_colon
 						goto mainparse
 					}
 
 				}
 
-				if fflib.EqualFoldRight(ffj_key_ConstMetric_Expires, kn) {
-					currentKey = ffj_t_ConstMetric_Expires
+				if fflib.EqualFoldRight(ffjKeyConstMetricExpires, kn) {
+					currentKey = ffjtConstMetricExpires
+					state = fflib.FFParse_want_colon
+					goto mainparse
+				}
+
+				if fflib.SimpleLetterEqualFold(ffjKeyConstMetricHelp, kn) {
+					currentKey = ffjtConstMetricHelp
 					state = fflib.FFParse_want_colon
 					goto mainparse
 				}
 
-				if fflib.SimpleLetterEqualFold(ffj_key_ConstMetric_Help, kn) {
-					currentKey = ffj_t_ConstMetric_Help
+				if fflib.EqualFoldRight(ffjKeyConstMetricLabels, kn) {
+					currentKey = ffjtConstMetricLabels
 					state = fflib.FFParse_want_colon
 					goto mainparse
 				}
 
-				if fflib.EqualFoldRight(ffj_key_ConstMetric_Labels, kn) {
-					currentKey = ffj_t_ConstMetric_Labels
+				if fflib.SimpleLetterEqualFold(ffjKeyConstMetricName, kn) {
+					currentKey = ffjtConstMetricName
 					state = fflib.FFParse_want_colon
 					goto mainparse
 				}
 
-				if fflib.SimpleLetterEqualFold(ffj_key_ConstMetric_Name, kn) {
-					currentKey = ffj_t_ConstMetric_Name
+				if fflib.EqualFoldRight(ffjKeyConstMetricStates, kn) {
+					currentKey = ffjtConstMetricStates
 					state = fflib.FFParse_want_colon
 					goto mainparse
 				}
 
-				if fflib.SimpleLetterEqualFold(ffj_key_ConstMetric_ValueType, kn) {
-					currentKey = ffj_t_ConstMetric_ValueType
+				if fflib.SimpleLetterEqualFold(ffjKeyConstMetricValueType, kn) {
+					currentKey = ffjtConstMetricValueType
 					state = fflib.FFParse_want_colon
 					goto mainparse
 				}
 
-				if fflib.SimpleLetterEqualFold(ffj_key_ConstMetric_Value, kn) {
-					currentKey = ffj_t_ConstMetric_Value
+				if fflib.SimpleLetterEqualFold(ffjKeyConstMetricValue, kn) {
+					currentKey = ffjtConstMetricValue
 					state = fflib.FFParse_want_colon
 					goto mainparse
 				}
 
-				currentKey = ffj_t_ConstMetricno_such_key
+				currentKey = ffjtConstMetricnosuchkey
 				state = fflib.FFParse_want_colon
 				goto mainparse
 			}
@@ -732,25 +971,28 @@ mainparse:
 			if tok == fflib.FFTok_left_brace || tok == fflib.FFTok_left_bracket || tok == fflib.FFTok_integer || tok == fflib.FFTok_double || tok == fflib.FFTok_string || tok == fflib.FFTok_bool || tok == fflib.FFTok_null {
 				switch currentKey {
 
-				case ffj_t_ConstMetric_Value:
+				case ffjtConstMetricValue:
 					goto handle_Value
 
-				case ffj_t_ConstMetric_ValueType:
+				case ffjtConstMetricValueType:
 					goto handle_ValueType
 
-				case ffj_t_ConstMetric_Name:
+				case ffjtConstMetricStates:
+					goto handle_States
+
+				case ffjtConstMetricName:
 					goto handle_Name
 
-				case ffj_t_ConstMetric_Labels:
+				case ffjtConstMetricLabels:
 					goto handle_Labels
 
-				case ffj_t_ConstMetric_Help:
+				case ffjtConstMetricHelp:
 					goto handle_Help
 
-				case ffj_t_ConstMetric_Expires:
+				case ffjtConstMetricExpires:
 					goto handle_Expires
 
-				case ffj_t_ConstMetricno_such_key:
+				case ffjtConstMetricnosuchkey:
 					err = fs.SkipField(tok)
 					if err != nil {
 						return fs.WrapErr(err)
@@ -766,7 +1008,7 @@ mainparse:
 
 handle_Value:
 
-	/* handler: uj.Value type=float64 kind=float64 */
+	/* handler: j.Value type=float64 kind=float64 quoted=false*/
 
 	{
 		if tok != fflib.FFTok_double && tok != fflib.FFTok_integer && tok != fflib.FFTok_null {
@@ -786,7 +1028,7 @@ handle_Value:
 				return fs.WrapErr(err)
 			}
 
-			uj.Value = float64(tval)
+			j.Value = float64(tval)
 
 		}
 	}
@@ -796,7 +1038,7 @@ handle_Value:
 
 handle_ValueType:
 
-	/* handler: uj.ValueType type=string kind=string */
+	/* handler: j.ValueType type=string kind=string quoted=false*/
 
 	{
 
@@ -810,7 +1052,9 @@ handle_ValueType:
 
 		} else {
 
-			uj.ValueType = string(fs.Output.String())
+			outBuf := fs.Output.Bytes()
+
+			j.ValueType = string(string(outBuf))
 
 		}
 	}
@@ -818,53 +1062,123 @@ handle_ValueType:
 	state = fflib.FFParse_after_value
 	goto mainparse
 
-handle_Name:
+handle_States:
 
-	/* handler: uj.Name type=string kind=string */
+	/* handler: j.States type=map[string]bool kind=map quoted=false*/
 
 	{
 
 		{
-			if tok != fflib.FFTok_string && tok != fflib.FFTok_null {
-				return fs.WrapErr(fmt.Errorf("cannot unmarshal %s into Go value for string", tok))
+			if tok != fflib.FFTok_left_bracket && tok != fflib.FFTok_null {
+				return fs.WrapErr(fmt.Errorf("cannot unmarshal %s into Go value for ", tok))
 			}
 		}
 
 		if tok == fflib.FFTok_null {
-
+			j.States = nil
 		} else {
 
-			uj.Name = string(fs.Output.String())
+			j.States = make(map[string]bool, 0)
 
-		}
-	}
+			wantVal := true
 
-	state = fflib.FFParse_after_value
-	goto mainparse
+			for {
 
-handle_Labels:
+				var k string
 
-	/* handler: uj.Labels type=map[string]string kind=map */
+				var tmpJStates bool
 
-	{
-		/* Falling back. type=map[string]string kind=map */
-		tbuf, err := fs.CaptureField(tok)
-		if err != nil {
-			return fs.WrapErr(err)
-		}
+				tok = fs.Scan()
+				if tok == fflib.FFTok_error {
+					goto tokerror
+				}
+				if tok == fflib.FFTok_right_bracket {
+					break
+				}
+
+				if tok == fflib.FFTok_comma {
+					if wantVal == true {
+						// TODO(pquerna): this isn't an ideal error message, this handles
+						// things like [,,,] as an array value.
+						return fs.WrapErr(fmt.Errorf("wanted value token, but got token: %v", tok))
+					}
+					continue
+				} else {
+					wantVal = true
+				}
+
+				/* handler: k type=string kind=string quoted=false*/
+
+				{
+
+					{
+						if tok != fflib.FFTok_string && tok != fflib.FFTok_null {
+							return fs.WrapErr(fmt.Errorf("cannot unmarshal %s into Go value for string", tok))
+						}
+					}
+
+					if tok == fflib.FFTok_null {
+
+					} else {
+
+						outBuf := fs.Output.Bytes()
+
+						k = string(string(outBuf))
+
+					}
+				}
+
+				// Expect ':' after key
+				tok = fs.Scan()
+				if tok != fflib.FFTok_colon {
+					return fs.WrapErr(fmt.Errorf("wanted colon token, but got token: %v", tok))
+				}
+
+				tok = fs.Scan()
+				/* handler: tmpJStates type=bool kind=bool quoted=false*/
+
+				{
+					if tok != fflib.FFTok_bool && tok != fflib.FFTok_null {
+						return fs.WrapErr(fmt.Errorf("cannot unmarshal %s into Go value for bool", tok))
+					}
+				}
+
+				{
+					if tok == fflib.FFTok_null {
+
+					} else {
+						tmpb := fs.Output.Bytes()
+
+						if bytes.Compare([]byte{'t', 'r', 'u', 'e'}, tmpb) == 0 {
+
+							tmpJStates = true
+
+						} else if bytes.Compare([]byte{'f', 'a', 'l', 's', 'e'}, tmpb) == 0 {
+
+							tmpJStates = false
+
+						} else {
+							err = errors.New("unexpected bytes for true/false value")
+							return fs.WrapErr(err)
+						}
+
+					}
+				}
+
+				j.States[k] = tmpJStates
+
+				wantVal = false
+			}
 
-		err = json.Unmarshal(tbuf, &uj.Labels)
-		if err != nil {
-			return fs.WrapErr(err)
 		}
 	}
 
 	state = fflib.FFParse_after_value
 	goto mainparse
 
-handle_Help:
+handle_Name:
 
-	/* handler: uj.Help type=string kind=string */
+	/* handler: j.Name type=string kind=string quoted=false*/
 
 	{
 
@@ -878,7 +1192,140 @@ handle_Help:
 
 		} else {
 
-			uj.Help = string(fs.Output.String())
+			outBuf := fs.Output.Bytes()
+
+			j.Name = string(string(outBuf))
+
+		}
+	}
+
+	state = fflib.FFParse_after_value
+	goto mainparse
+
+handle_Labels:
+
+	/* handler: j.Labels type=map[string]string kind=map quoted=false*/
+
+	{
+
+		{
+			if tok != fflib.FFTok_left_bracket && tok != fflib.FFTok_null {
+				return fs.WrapErr(fmt.Errorf("cannot unmarshal %s into Go value for ", tok))
+			}
+		}
+
+		if tok == fflib.FFTok_null {
+			j.Labels = nil
+		} else {
+
+			j.Labels = make(map[string]string, 0)
+
+			wantVal := true
+
+			for {
+
+				var k string
+
+				var tmpJLabels string
+
+				tok = fs.Scan()
+				if tok == fflib.FFTok_error {
+					goto tokerror
+				}
+				if tok == fflib.FFTok_right_bracket {
+					break
+				}
+
+				if tok == fflib.FFTok_comma {
+					if wantVal == true {
+						// TODO(pquerna): this isn't an ideal error message, this handles
+						// things like [,,,] as an array value.
+						return fs.WrapErr(fmt.Errorf("wanted value token, but got token: %v", tok))
+					}
+					continue
+				} else {
+					wantVal = true
+				}
+
+				/* handler: k type=string kind=string quoted=false*/
+
+				{
+
+					{
+						if tok != fflib.FFTok_string && tok != fflib.FFTok_null {
+							return fs.WrapErr(fmt.Errorf("cannot unmarshal %s into Go value for string", tok))
+						}
+					}
+
+					if tok == fflib.FFTok_null {
+
+					} else {
+
+						outBuf := fs.Output.Bytes()
+
+						k = string(string(outBuf))
+
+					}
+				}
+
+				// Expect ':' after key
+				tok = fs.Scan()
+				if tok != fflib.FFTok_colon {
+					return fs.WrapErr(fmt.Errorf("wanted colon token, but got token: %v", tok))
+				}
+
+				tok = fs.Scan()
+				/* handler: tmpJLabels type=string kind=string quoted=false*/
+
+				{
+
+					{
+						if tok != fflib.FFTok_string && tok != fflib.FFTok_null {
+							return fs.WrapErr(fmt.Errorf("cannot unmarshal %s into Go value for string", tok))
+						}
+					}
+
+					if tok == fflib.FFTok_null {
+
+					} else {
+
+						outBuf := fs.Output.Bytes()
+
+						tmpJLabels = string(string(outBuf))
+
+					}
+				}
+
+				j.Labels[k] = tmpJLabels
+
+				wantVal = false
+			}
+
+		}
+	}
+
+	state = fflib.FFParse_after_value
+	goto mainparse
+
+handle_Help:
+
+	/* handler: j.Help type=string kind=string quoted=false*/
+
+	{
+
+		{
+			if tok != fflib.FFTok_string && tok != fflib.FFTok_null {
+				return fs.WrapErr(fmt.Errorf("cannot unmarshal %s into Go value for string", tok))
+			}
+		}
+
+		if tok == fflib.FFTok_null {
+
+		} else {
+
+			outBuf := fs.Output.Bytes()
+
+			j.Help = string(string(outBuf))
 
 		}
 	}
@@ -888,7 +1335,7 @@ handle_Help:
 
 handle_Expires:
 
-	/* handler: uj.Expires type=int64 kind=int64 */
+	/* handler: j.Expires type=int64 kind=int64 quoted=false*/
 
 	{
 		if tok != fflib.FFTok_integer && tok != fflib.FFTok_null {
@@ -908,7 +1355,7 @@ handle_Expires:
 				return fs.WrapErr(err)
 			}
 
-			uj.Expires = int64(tval)
+			j.Expires = int64(tval)
 
 		}
 	}
@@ -930,31 +1377,43 @@ tokerror:
 	}
 	panic("ffjson-generated: unreachable, please report bug.")
 done:
+
 	return nil
 }
 
-func (mj *ConstSummary) MarshalJSON() ([]byte, error) {
+// MarshalJSON marshal bytes to json - template
+func (j *ConstSummary) MarshalJSON() ([]byte, error) {
 	var buf fflib.Buffer
-	err := mj.MarshalJSONBuf(&buf)
+	if j == nil {
+		buf.WriteString("null")
+		return buf.Bytes(), nil
+	}
+	err := j.MarshalJSONBuf(&buf)
 	if err != nil {
 		return nil, err
 	}
 	return buf.Bytes(), nil
 }
-func (mj *ConstSummary) MarshalJSONBuf(buf fflib.EncodingBuffer) error {
+
+// MarshalJSONBuf marshal buff to json - template
+func (j *ConstSummary) MarshalJSONBuf(buf fflib.EncodingBuffer) error {
+	if j == nil {
+		buf.WriteString("null")
+		return nil
+	}
 	var err error
 	var obj []byte
 	_ = obj
 	_ = err
 	buf.WriteString(`{"Count":`)
-	fflib.FormatBits2(buf, uint64(mj.Count), 10, false)
+	fflib.FormatBits2(buf, uint64(j.Count), 10, false)
 	buf.WriteString(`,"Sum":`)
-	fflib.AppendFloat(buf, float64(mj.Sum), 'g', -1, 64)
-	if mj.Quantiles == nil {
+	fflib.AppendFloat(buf, float64(j.Sum), 'g', -1, 64)
+	if j.Quantiles == nil {
 		buf.WriteString(`,"Quantiles":null`)
 	} else {
 		buf.WriteString(`,"Quantiles":{ `)
-		for key, value := range mj.Quantiles {
+		for key, value := range j.Quantiles {
 			fflib.WriteJsonString(buf, key)
 			buf.WriteString(`:`)
 			fflib.AppendFloat(buf, float64(value), 'g', -1, 64)
@@ -964,12 +1423,12 @@ func (mj *ConstSummary) MarshalJSONBuf(buf fflib.EncodingBuffer) error {
 		buf.WriteByte('}')
 	}
 	buf.WriteString(`,"Name":`)
-	fflib.WriteJsonString(buf, string(mj.Name))
-	if mj.Labels == nil {
+	fflib.WriteJsonString(buf, string(j.Name))
+	if j.Labels == nil {
 		buf.WriteString(`,"Labels":null`)
 	} else {
 		buf.WriteString(`,"Labels":{ `)
-		for key, value := range mj.Labels {
+		for key, value := range j.Labels {
 			fflib.WriteJsonString(buf, key)
 			buf.WriteString(`:`)
 			fflib.WriteJsonString(buf, string(value))
@@ -979,54 +1438,56 @@ func (mj *ConstSummary) MarshalJSONBuf(buf fflib.EncodingBuffer) error {
 		buf.WriteByte('}')
 	}
 	buf.WriteString(`,"Help":`)
-	fflib.WriteJsonString(buf, string(mj.Help))
+	fflib.WriteJsonString(buf, string(j.Help))
 	buf.WriteString(`,"Expires":`)
-	fflib.FormatBits2(buf, uint64(mj.Expires), 10, mj.Expires < 0)
+	fflib.FormatBits2(buf, uint64(j.Expires), 10, j.Expires < 0)
 	buf.WriteByte('}')
 	return nil
 }
 
 const (
-	ffj_t_ConstSummarybase = iota
-	ffj_t_ConstSummaryno_such_key
+	ffjtConstSummarybase = iota
+	ffjtConstSummarynosuchkey
 
-	ffj_t_ConstSummary_Count
+	ffjtConstSummaryCount
 
-	ffj_t_ConstSummary_Sum
+	ffjtConstSummarySum
 
-	ffj_t_ConstSummary_Quantiles
+	ffjtConstSummaryQuantiles
 
-	ffj_t_ConstSummary_Name
+	ffjtConstSummaryName
 
-	ffj_t_ConstSummary_Labels
+	ffjtConstSummaryLabels
 
-	ffj_t_ConstSummary_Help
+	ffjtConstSummaryHelp
 
-	ffj_t_ConstSummary_Expires
+	ffjtConstSummaryExpires
 )
 
-var ffj_key_ConstSummary_Count = []byte("Count")
+var ffjKeyConstSummaryCount = []byte("Count")
 
-var ffj_key_ConstSummary_Sum = []byte("Sum")
+var ffjKeyConstSummarySum = []byte("Sum")
 
-var ffj_key_ConstSummary_Quantiles = []byte("Quantiles")
+var ffjKeyConstSummaryQuantiles = []byte("Quantiles")
 
-var ffj_key_ConstSummary_Name = []byte("Name")
+var ffjKeyConstSummaryName = []byte("Name")
 
-var ffj_key_ConstSummary_Labels = []byte("Labels")
+var ffjKeyConstSummaryLabels = []byte("Labels")
 
-var ffj_key_ConstSummary_Help = []byte("Help")
+var ffjKeyConstSummaryHelp = []byte("Help")
 
-var ffj_key_ConstSummary_Expires = []byte("Expires")
+var ffjKeyConstSummaryExpires = []byte("Expires")
 
-func (uj *ConstSummary) UnmarshalJSON(input []byte) error {
+// UnmarshalJSON umarshall json - template of ffjson
+func (j *ConstSummary) UnmarshalJSON(input []byte) error {
 	fs := fflib.NewFFLexer(input)
-	return uj.UnmarshalJSONFFLexer(fs, fflib.FFParse_map_start)
+	return j.UnmarshalJSONFFLexer(fs, fflib.FFParse_map_start)
 }
 
-func (uj *ConstSummary) UnmarshalJSONFFLexer(fs *fflib.FFLexer, state fflib.FFParseState) error {
-	var err error = nil
-	currentKey := ffj_t_ConstSummarybase
+// UnmarshalJSONFFLexer fast json unmarshall - template ffjson
+func (j *ConstSummary) UnmarshalJSONFFLexer(fs *fflib.FFLexer, state fflib.FFParseState) error {
+	var err error
+	currentKey := ffjtConstSummarybase
 	_ = currentKey
 	tok := fflib.FFTok_init
 	wantedTok := fflib.FFTok_init
@@ -1072,7 +1533,7 @@ mainparse:
 			kn := fs.Output.Bytes()
 			if len(kn) <= 0 {
 				// "" case. hrm.
-				currentKey = ffj_t_ConstSummaryno_such_key
+				currentKey = ffjtConstSummarynosuchkey
 				state = fflib.FFParse_want_colon
 				goto mainparse
 			} else {
@@ -1080,105 +1541,105 @@ mainparse:
 
 				case 'C':
 
-					if bytes.Equal(ffj_key_ConstSummary_Count, kn) {
-						currentKey = ffj_t_ConstSummary_Count
+					if bytes.Equal(ffjKeyConstSummaryCount, kn) {
+						currentKey = ffjtConstSummaryCount
 						state = fflib.FFParse_want_colon
 						goto mainparse
 					}
 
 				case 'E':
 
-					if bytes.Equal(ffj_key_ConstSummary_Expires, kn) {
-						currentKey = ffj_t_ConstSummary_Expires
+					if bytes.Equal(ffjKeyConstSummaryExpires, kn) {
+						currentKey = ffjtConstSummaryExpires
 						state = fflib.FFParse_want_colon
 						goto mainparse
 					}
 
 				case 'H':
 
-					if bytes.Equal(ffj_key_ConstSummary_Help, kn) {
-						currentKey = ffj_t_ConstSummary_Help
+					if bytes.Equal(ffjKeyConstSummaryHelp, kn) {
+						currentKey = ffjtConstSummaryHelp
 						state = fflib.FFParse_want_colon
 						goto mainparse
 					}
 
 				case 'L':
 
-					if bytes.Equal(ffj_key_ConstSummary_Labels, kn) {
-						currentKey = ffj_t_ConstSummary_Labels
+					if bytes.Equal(ffjKeyConstSummaryLabels, kn) {
+						currentKey = ffjtConstSummaryLabels
 						state = fflib.FFParse_want_colon
 						goto mainparse
 					}
 
 				case 'N':
 
-					if bytes.Equal(ffj_key_ConstSummary_Name, kn) {
-						currentKey = ffj_t_ConstSummary_Name
+					if bytes.Equal(ffjKeyConstSummaryName, kn) {
+						currentKey = ffjtConstSummaryName
 						state = fflib.FFParse_want_colon
 						goto mainparse
 					}
 
 				case 'Q':
 
-					if bytes.Equal(ffj_key_ConstSummary_Quantiles, kn) {
-						currentKey = ffj_t_ConstSummary_Quantiles
+					if bytes.Equal(ffjKeyConstSummaryQuantiles, kn) {
+						currentKey = ffjtConstSummaryQuantiles
 						state = fflib.FFParse_want_colon
 						goto mainparse
 					}
 
 				case 'S':
 
-					if bytes.Equal(ffj_key_ConstSummary_Sum, kn) {
-						currentKey = ffj_t_ConstSummary_Sum
+					if bytes.Equal(ffjKeyConstSummarySum, kn) {
+						currentKey = ffjtConstSummarySum
 						state = fflib.FFParse_want_colon
 						goto mainparse
 					}
 
 				}
 
-				if fflib.EqualFoldRight(ffj_key_ConstSummary_Expires, kn) {
-					currentKey = ffj_t_ConstSummary_Expires
+				if fflib.EqualFoldRight(ffjKeyConstSummaryExpires, kn) {
+					currentKey = ffjtConstSummaryExpires
 					state = fflib.FFParse_want_colon
 					goto mainparse
 				}
 
-				if fflib.SimpleLetterEqualFold(ffj_key_ConstSummary_Help, kn) {
-					currentKey = ffj_t_ConstSummary_Help
+				if fflib.SimpleLetterEqualFold(ffjKeyConstSummaryHelp, kn) {
+					currentKey = ffjtConstSummaryHelp
 					state = fflib.FFParse_want_colon
 					goto mainparse
 				}
 
-				if fflib.EqualFoldRight(ffj_key_ConstSummary_Labels, kn) {
-					currentKey = ffj_t_ConstSummary_Labels
+				if fflib.EqualFoldRight(ffjKeyConstSummaryLabels, kn) {
+					currentKey = ffjtConstSummaryLabels
 					state = fflib.FFParse_want_colon
 					goto mainparse
 				}
 
-				if fflib.SimpleLetterEqualFold(ffj_key_ConstSummary_Name, kn) {
-					currentKey = ffj_t_ConstSummary_Name
+				if fflib.SimpleLetterEqualFold(ffjKeyConstSummaryName, kn) {
+					currentKey = ffjtConstSummaryName
 					state = fflib.FFParse_want_colon
 					goto mainparse
 				}
 
-				if fflib.EqualFoldRight(ffj_key_ConstSummary_Quantiles, kn) {
-					currentKey = ffj_t_ConstSummary_Quantiles
+				if fflib.EqualFoldRight(ffjKeyConstSummaryQuantiles, kn) {
+					currentKey = ffjtConstSummaryQuantiles
 					state = fflib.FFParse_want_colon
 					goto mainparse
 				}
 
-				if fflib.EqualFoldRight(ffj_key_ConstSummary_Sum, kn) {
-					currentKey = ffj_t_ConstSummary_Sum
+				if fflib.EqualFoldRight(ffjKeyConstSummarySum, kn) {
+					currentKey = ffjtConstSummarySum
 					state = fflib.FFParse_want_colon
 					goto mainparse
 				}
 
-				if fflib.SimpleLetterEqualFold(ffj_key_ConstSummary_Count, kn) {
-					currentKey = ffj_t_ConstSummary_Count
+				if fflib.SimpleLetterEqualFold(ffjKeyConstSummaryCount, kn) {
+					currentKey = ffjtConstSummaryCount
 					state = fflib.FFParse_want_colon
 					goto mainparse
 				}
 
-				currentKey = ffj_t_ConstSummaryno_such_key
+				currentKey = ffjtConstSummarynosuchkey
 				state = fflib.FFParse_want_colon
 				goto mainparse
 			}
@@ -1195,28 +1656,28 @@ mainparse:
 			if tok == fflib.FFTok_left_brace || tok == fflib.FFTok_left_bracket || tok == fflib.FFTok_integer || tok == fflib.FFTok_double || tok == fflib.FFTok_string || tok == fflib.FFTok_bool || tok == fflib.FFTok_null {
 				switch currentKey {
 
-				case ffj_t_ConstSummary_Count:
+				case ffjtConstSummaryCount:
 					goto handle_Count
 
-				case ffj_t_ConstSummary_Sum:
+				case ffjtConstSummarySum:
 					goto handle_Sum
 
-				case ffj_t_ConstSummary_Quantiles:
+				case ffjtConstSummaryQuantiles:
 					goto handle_Quantiles
 
-				case ffj_t_ConstSummary_Name:
+				case ffjtConstSummaryName:
 					goto handle_Name
 
-				case ffj_t_ConstSummary_Labels:
+				case ffjtConstSummaryLabels:
 					goto handle_Labels
 
-				case ffj_t_ConstSummary_Help:
+				case ffjtConstSummaryHelp:
 					goto handle_Help
 
-				case ffj_t_ConstSummary_Expires:
+				case ffjtConstSummaryExpires:
 					goto handle_Expires
 
-				case ffj_t_ConstSummaryno_such_key:
+				case ffjtConstSummarynosuchkey:
 					err = fs.SkipField(tok)
 					if err != nil {
 						return fs.WrapErr(err)
@@ -1232,7 +1693,7 @@ mainparse:
 
 handle_Count:
 
-	/* handler: uj.Count type=uint64 kind=uint64 */
+	/* handler: j.Count type=uint64 kind=uint64 quoted=false*/
 
 	{
 		if tok != fflib.FFTok_integer && tok != fflib.FFTok_null {
@@ -1252,7 +1713,7 @@ handle_Count:
 				return fs.WrapErr(err)
 			}
 
-			uj.Count = uint64(tval)
+			j.Count = uint64(tval)
 
 		}
 	}
@@ -1262,7 +1723,7 @@ handle_Count:
 
 handle_Sum:
 
-	/* handler: uj.Sum type=float64 kind=float64 */
+	/* handler: j.Sum type=float64 kind=float64 quoted=false*/
 
 	{
 		if tok != fflib.FFTok_double && tok != fflib.FFTok_integer && tok != fflib.FFTok_null {
@@ -1282,7 +1743,7 @@ handle_Sum:
 				return fs.WrapErr(err)
 			}
 
-			uj.Sum = float64(tval)
+			j.Sum = float64(tval)
 
 		}
 	}
@@ -1292,18 +1753,107 @@ handle_Sum:
 
 handle_Quantiles:
 
-	/* handler: uj.Quantiles type=map[string]float64 kind=map */
+	/* handler: j.Quantiles type=map[string]float64 kind=map quoted=false*/
 
 	{
-		/* Falling back. type=map[string]float64 kind=map */
-		tbuf, err := fs.CaptureField(tok)
-		if err != nil {
-			return fs.WrapErr(err)
+
+		{
+			if tok != fflib.FFTok_left_bracket && tok != fflib.FFTok_null {
+				return fs.WrapErr(fmt.Errorf("cannot unmarshal %s into Go value for ", tok))
+			}
 		}
 
-		err = json.Unmarshal(tbuf, &uj.Quantiles)
-		if err != nil {
-			return fs.WrapErr(err)
+		if tok == fflib.FFTok_null {
+			j.Quantiles = nil
+		} else {
+
+			j.Quantiles = make(map[string]float64, 0)
+
+			wantVal := true
+
+			for {
+
+				var k string
+
+				var tmpJQuantiles float64
+
+				tok = fs.Scan()
+				if tok == fflib.FFTok_error {
+					goto tokerror
+				}
+				if tok == fflib.FFTok_right_bracket {
+					break
+				}
+
+				if tok == fflib.FFTok_comma {
+					if wantVal == true {
+						// TODO(pquerna): this isn't an ideal error message, this handles
+						// things like [,,,] as an array value.
+						return fs.WrapErr(fmt.Errorf("wanted value token, but got token: %v", tok))
+					}
+					continue
+				} else {
+					wantVal = true
+				}
+
+				/* handler: k type=string kind=string quoted=false*/
+
+				{
+
+					{
+						if tok != fflib.FFTok_string && tok != fflib.FFTok_null {
+							return fs.WrapErr(fmt.Errorf("cannot unmarshal %s into Go value for string", tok))
+						}
+					}
+
+					if tok == fflib.FFTok_null {
+
+					} else {
+
+						outBuf := fs.Output.Bytes()
+
+						k = string(string(outBuf))
+
+					}
+				}
+
+				// Expect ':' after key
+				tok = fs.Scan()
+				if tok != fflib.FFTok_colon {
+					return fs.WrapErr(fmt.Errorf("wanted colon token, but got token: %v", tok))
+				}
+
+				tok = fs.Scan()
+				/* handler: tmpJQuantiles type=float64 kind=float64 quoted=false*/
+
+				{
+					if tok != fflib.FFTok_double && tok != fflib.FFTok_integer && tok != fflib.FFTok_null {
+						return fs.WrapErr(fmt.Errorf("cannot unmarshal %s into Go value for float64", tok))
+					}
+				}
+
+				{
+
+					if tok == fflib.FFTok_null {
+
+					} else {
+
+						tval, err := fflib.ParseFloat(fs.Output.Bytes(), 64)
+
+						if err != nil {
+							return fs.WrapErr(err)
+						}
+
+						tmpJQuantiles = float64(tval)
+
+					}
+				}
+
+				j.Quantiles[k] = tmpJQuantiles
+
+				wantVal = false
+			}
+
 		}
 	}
 
@@ -1312,7 +1862,7 @@ handle_Quantiles:
 
 handle_Name:
 
-	/* handler: uj.Name type=string kind=string */
+	/* handler: j.Name type=string kind=string quoted=false*/
 
 	{
 
@@ -1326,7 +1876,9 @@ handle_Name:
 
 		} else {
 
-			uj.Name = string(fs.Output.String())
+			outBuf := fs.Output.Bytes()
+
+			j.Name = string(string(outBuf))
 
 		}
 	}
@@ -1336,18 +1888,103 @@ handle_Name:
 
 handle_Labels:
 
-	/* handler: uj.Labels type=map[string]string kind=map */
+	/* handler: j.Labels type=map[string]string kind=map quoted=false*/
 
 	{
-		/* Falling back. type=map[string]string kind=map */
-		tbuf, err := fs.CaptureField(tok)
-		if err != nil {
-			return fs.WrapErr(err)
+
+		{
+			if tok != fflib.FFTok_left_bracket && tok != fflib.FFTok_null {
+				return fs.WrapErr(fmt.Errorf("cannot unmarshal %s into Go value for ", tok))
+			}
 		}
 
-		err = json.Unmarshal(tbuf, &uj.Labels)
-		if err != nil {
-			return fs.WrapErr(err)
+		if tok == fflib.FFTok_null {
+			j.Labels = nil
+		} else {
+
+			j.Labels = make(map[string]string, 0)
+
+			wantVal := true
+
+			for {
+
+				var k string
+
+				var tmpJLabels string
+
+				tok = fs.Scan()
+				if tok == fflib.FFTok_error {
+					goto tokerror
+				}
+				if tok == fflib.FFTok_right_bracket {
+					break
+				}
+
+				if tok == fflib.FFTok_comma {
+					if wantVal == true {
+						// TODO(pquerna): this isn't an ideal error message, this handles
+						// things like [,,,] as an array value.
+						return fs.WrapErr(fmt.Errorf("wanted value token, but got token: %v", tok))
+					}
+					continue
+				} else {
+					wantVal = true
+				}
+
+				/* handler: k type=string kind=string quoted=false*/
+
+				{
+
+					{
+						if tok != fflib.FFTok_string && tok != fflib.FFTok_null {
+							return fs.WrapErr(fmt.Errorf("cannot unmarshal %s into Go value for string", tok))
+						}
+					}
+
+					if tok == fflib.FFTok_null {
+
+					} else {
+
+						outBuf := fs.Output.Bytes()
+
+						k = string(string(outBuf))
+
+					}
+				}
+
+				// Expect ':' after key
+				tok = fs.Scan()
+				if tok != fflib.FFTok_colon {
+					return fs.WrapErr(fmt.Errorf("wanted colon token, but got token: %v", tok))
+				}
+
+				tok = fs.Scan()
+				/* handler: tmpJLabels type=string kind=string quoted=false*/
+
+				{
+
+					{
+						if tok != fflib.FFTok_string && tok != fflib.FFTok_null {
+							return fs.WrapErr(fmt.Errorf("cannot unmarshal %s into Go value for string", tok))
+						}
+					}
+
+					if tok == fflib.FFTok_null {
+
+					} else {
+
+						outBuf := fs.Output.Bytes()
+
+						tmpJLabels = string(string(outBuf))
+
+					}
+				}
+
+				j.Labels[k] = tmpJLabels
+
+				wantVal = false
+			}
+
 		}
 	}
 
@@ -1356,7 +1993,7 @@ handle_Labels:
 
 handle_Help:
 
-	/* handler: uj.Help type=string kind=string */
+	/* handler: j.Help type=string kind=string quoted=false*/
 
 	{
 
@@ -1370,7 +2007,9 @@ handle_Help:
 
 		} else {
 
-			uj.Help = string(fs.Output.String())
+			outBuf := fs.Output.Bytes()
+
+			j.Help = string(string(outBuf))
 
 		}
 	}
@@ -1380,7 +2019,7 @@ handle_Help:
 
 handle_Expires:
 
-	/* handler: uj.Expires type=int64 kind=int64 */
+	/* handler: j.Expires type=int64 kind=int64 quoted=false*/
 
 	{
 		if tok != fflib.FFTok_integer && tok != fflib.FFTok_null {
@@ -1400,7 +2039,7 @@ handle_Expires:
 				return fs.WrapErr(err)
 			}
 
-			uj.Expires = int64(tval)
+			j.Expires = int64(tval)
 
 		}
 	}
@@ -1422,77 +2061,87 @@ tokerror:
 	}
 	panic("ffjson-generated: unreachable, please report bug.")
 done:
+
 	return nil
 }
 
-func (mj *Metrics) MarshalJSON() ([]byte, error) {
+// MarshalJSON marshal bytes to json - template
+func (j *ConstVector) MarshalJSON() ([]byte, error) {
 	var buf fflib.Buffer
-	err := mj.MarshalJSONBuf(&buf)
+	if j == nil {
+		buf.WriteString("null")
+		return buf.Bytes(), nil
+	}
+	err := j.MarshalJSONBuf(&buf)
 	if err != nil {
 		return nil, err
 	}
 	return buf.Bytes(), nil
 }
-func (mj *Metrics) MarshalJSONBuf(buf fflib.EncodingBuffer) error {
+
+// MarshalJSONBuf marshal buff to json - template
+func (j *ConstVector) MarshalJSONBuf(buf fflib.EncodingBuffer) error {
+	if j == nil {
+		buf.WriteString("null")
+		return nil
+	}
 	var err error
 	var obj []byte
 	_ = obj
 	_ = err
-	buf.WriteString(`{"Single":`)
-	if mj.Single != nil {
+	buf.WriteString(`{"Name":`)
+	fflib.WriteJsonString(buf, string(j.Name))
+	buf.WriteString(`,"ValueType":`)
+	fflib.WriteJsonString(buf, string(j.ValueType))
+	buf.WriteString(`,"Help":`)
+	fflib.WriteJsonString(buf, string(j.Help))
+	buf.WriteString(`,"Expires":`)
+	fflib.FormatBits2(buf, uint64(j.Expires), 10, j.Expires < 0)
+	buf.WriteString(`,"LabelNames":`)
+	if j.LabelNames != nil {
 		buf.WriteString(`[`)
-		for i, v := range mj.Single {
+		for i, v := range j.LabelNames {
 			if i != 0 {
 				buf.WriteString(`,`)
 			}
-
-			{
-				err = v.MarshalJSONBuf(buf)
-				if err != nil {
-					return err
-				}
-			}
-
+			fflib.WriteJsonString(buf, string(v))
 		}
 		buf.WriteString(`]`)
 	} else {
 		buf.WriteString(`null`)
 	}
-	buf.WriteString(`,"Summary":`)
-	if mj.Summary != nil {
+	buf.WriteString(`,"LabelValues":`)
+	if j.LabelValues != nil {
 		buf.WriteString(`[`)
-		for i, v := range mj.Summary {
+		for i, v := range j.LabelValues {
 			if i != 0 {
 				buf.WriteString(`,`)
 			}
-
-			{
-				err = v.MarshalJSONBuf(buf)
-				if err != nil {
-					return err
+			if v != nil {
+				buf.WriteString(`[`)
+				for i, v := range v {
+					if i != 0 {
+						buf.WriteString(`,`)
+					}
+					fflib.WriteJsonString(buf, string(v))
 				}
+				buf.WriteString(`]`)
+			} else {
+				buf.WriteString(`null`)
 			}
-
 		}
 		buf.WriteString(`]`)
 	} else {
 		buf.WriteString(`null`)
 	}
-	buf.WriteString(`,"Histogram":`)
-	if mj.Histogram != nil {
+	buf.WriteString(`,"Values":`)
+	if j.Values != nil {
 		buf.WriteString(`[`)
-		for i, v := range mj.Histogram {
+		for i, v := range j.Values {
 			if i != 0 {
 				buf.WriteString(`,`)
 			}
-
-			{
-				err = v.MarshalJSONBuf(buf)
-				if err != nil {
-					return err
-				}
-			}
-
+			fflib.AppendFloat(buf, float64(v), 'g', -1, 64)
 		}
 		buf.WriteString(`]`)
 	} else {
@@ -1503,30 +2152,48 @@ func (mj *Metrics) MarshalJSONBuf(buf fflib.EncodingBuffer) error {
 }
 
 const (
-	ffj_t_Metricsbase = iota
-	ffj_t_Metricsno_such_key
+	ffjtConstVectorbase = iota
+	ffjtConstVectornosuchkey
+
+	ffjtConstVectorName
 
-	ffj_t_Metrics_Single
+	ffjtConstVectorValueType
 
-	ffj_t_Metrics_Summary
+	ffjtConstVectorHelp
 
-	ffj_t_Metrics_Histogram
+	ffjtConstVectorExpires
+
+	ffjtConstVectorLabelNames
+
+	ffjtConstVectorLabelValues
+
+	ffjtConstVectorValues
 )
 
-var ffj_key_Metrics_Single = []byte("Single")
+var ffjKeyConstVectorName = []byte("Name")
+
+var ffjKeyConstVectorValueType = []byte("ValueType")
 
-var ffj_key_Metrics_Summary = []byte("Summary")
+var ffjKeyConstVectorHelp = []byte("Help")
 
-var ffj_key_Metrics_Histogram = []byte("Histogram")
+var ffjKeyConstVectorExpires = []byte("Expires")
 
-func (uj *Metrics) UnmarshalJSON(input []byte) error {
+var ffjKeyConstVectorLabelNames = []byte("LabelNames")
+
+var ffjKeyConstVectorLabelValues = []byte("LabelValues")
+
+var ffjKeyConstVectorValues = []byte("Values")
+
+// UnmarshalJSON umarshall json - template of ffjson
+func (j *ConstVector) UnmarshalJSON(input []byte) error {
 	fs := fflib.NewFFLexer(input)
-	return uj.UnmarshalJSONFFLexer(fs, fflib.FFParse_map_start)
+	return j.UnmarshalJSONFFLexer(fs, fflib.FFParse_map_start)
 }
 
-func (uj *Metrics) UnmarshalJSONFFLexer(fs *fflib.FFLexer, state fflib.FFParseState) error {
-	var err error = nil
-	currentKey := ffj_t_Metricsbase
+// UnmarshalJSONFFLexer fast json unmarshall - template ffjson
+func (j *ConstVector) UnmarshalJSONFFLexer(fs *fflib.FFLexer, state fflib.FFParseState) error {
+	var err error
+	currentKey := ffjtConstVectorbase
 	_ = currentKey
 	tok := fflib.FFTok_init
 	wantedTok := fflib.FFTok_init
@@ -1572,54 +2239,107 @@ mainparse:
 			kn := fs.Output.Bytes()
 			if len(kn) <= 0 {
 				// "" case. hrm.
-				currentKey = ffj_t_Metricsno_such_key
+				currentKey = ffjtConstVectornosuchkey
 				state = fflib.FFParse_want_colon
 				goto mainparse
 			} else {
 				switch kn[0] {
 
+				case 'E':
+
+					if bytes.Equal(ffjKeyConstVectorExpires, kn) {
+						currentKey = ffjtConstVectorExpires
+						state = fflib.FFParse_want_colon
+						goto mainparse
+					}
+
 				case 'H':
 
-					if bytes.Equal(ffj_key_Metrics_Histogram, kn) {
-						currentKey = ffj_t_Metrics_Histogram
+					if bytes.Equal(ffjKeyConstVectorHelp, kn) {
+						currentKey = ffjtConstVectorHelp
 						state = fflib.FFParse_want_colon
 						goto mainparse
 					}
 
-				case 'S':
+				case 'L':
+
+					if bytes.Equal(ffjKeyConstVectorLabelNames, kn) {
+						currentKey = ffjtConstVectorLabelNames
+						state = fflib.FFParse_want_colon
+						goto mainparse
+
+					} else if bytes.Equal(ffjKeyConstVectorLabelValues, kn) {
+						currentKey = ffjtConstVectorLabelValues
+						state = fflib.FFParse_want_colon
+						goto mainparse
+					}
+
+				case 'N':
+
+					if bytes.Equal(ffjKeyConstVectorName, kn) {
+						currentKey = ffjtConstVectorName
+						state = fflib.FFParse_want_colon
+						goto mainparse
+					}
+
+				case 'V':
 
-					if bytes.Equal(ffj_key_Metrics_Single, kn) {
-						currentKey = ffj_t_Metrics_Single
+					if bytes.Equal(ffjKeyConstVectorValueType, kn) {
+						currentKey = ffjtConstVectorValueType
 						state = fflib.FFParse_want_colon
 						goto mainparse
 
-					} else if bytes.Equal(ffj_key_Metrics_Summary, kn) {
-						currentKey = ffj_t_Metrics_Summary
+					} else if bytes.Equal(ffjKeyConstVectorValues, kn) {
+						currentKey = ffjtConstVectorValues
 						state = fflib.FFParse_want_colon
 						goto mainparse
 					}
 
 				}
 
-				if fflib.EqualFoldRight(ffj_key_Metrics_Histogram, kn) {
-					currentKey = ffj_t_Metrics_Histogram
+				if fflib.EqualFoldRight(ffjKeyConstVectorValues, kn) {
+					currentKey = ffjtConstVectorValues
+					state = fflib.FFParse_want_colon
+					goto mainparse
+				}
+
+				if fflib.EqualFoldRight(ffjKeyConstVectorLabelValues, kn) {
+					currentKey = ffjtConstVectorLabelValues
+					state = fflib.FFParse_want_colon
+					goto mainparse
+				}
+
+				if fflib.EqualFoldRight(ffjKeyConstVectorLabelNames, kn) {
+					currentKey = ffjtConstVectorLabelNames
+					state = fflib.FFParse_want_colon
+					goto mainparse
+				}
+
+				if fflib.EqualFoldRight(ffjKeyConstVectorExpires, kn) {
+					currentKey = ffjtConstVectorExpires
+					state = fflib.FFParse_want_colon
+					goto mainparse
+				}
+
+				if fflib.SimpleLetterEqualFold(ffjKeyConstVectorHelp, kn) {
+					currentKey = ffjtConstVectorHelp
 					state = fflib.FFParse_want_colon
 					goto mainparse
 				}
 
-				if fflib.EqualFoldRight(ffj_key_Metrics_Summary, kn) {
-					currentKey = ffj_t_Metrics_Summary
+				if fflib.SimpleLetterEqualFold(ffjKeyConstVectorValueType, kn) {
+					currentKey = ffjtConstVectorValueType
 					state = fflib.FFParse_want_colon
 					goto mainparse
 				}
 
-				if fflib.EqualFoldRight(ffj_key_Metrics_Single, kn) {
-					currentKey = ffj_t_Metrics_Single
+				if fflib.SimpleLetterEqualFold(ffjKeyConstVectorName, kn) {
+					currentKey = ffjtConstVectorName
 					state = fflib.FFParse_want_colon
 					goto mainparse
 				}
 
-				currentKey = ffj_t_Metricsno_such_key
+				currentKey = ffjtConstVectornosuchkey
 				state = fflib.FFParse_want_colon
 				goto mainparse
 			}
@@ -1636,16 +2356,28 @@ mainparse:
 			if tok == fflib.FFTok_left_brace || tok == fflib.FFTok_left_bracket || tok == fflib.FFTok_integer || tok == fflib.FFTok_double || tok == fflib.FFTok_string || tok == fflib.FFTok_bool || tok == fflib.FFTok_null {
 				switch currentKey {
 
-				case ffj_t_Metrics_Single:
-					goto handle_Single
+				case ffjtConstVectorName:
+					goto handle_Name
 
-				case ffj_t_Metrics_Summary:
-					goto handle_Summary
+				case ffjtConstVectorValueType:
+					goto handle_ValueType
 
-				case ffj_t_Metrics_Histogram:
-					goto handle_Histogram
+				case ffjtConstVectorHelp:
+					goto handle_Help
+
+				case ffjtConstVectorExpires:
+					goto handle_Expires
+
+				case ffjtConstVectorLabelNames:
+					goto handle_LabelNames
 
-				case ffj_t_Metricsno_such_key:
+				case ffjtConstVectorLabelValues:
+					goto handle_LabelValues
+
+				case ffjtConstVectorValues:
+					goto handle_Values
+
+				case ffjtConstVectornosuchkey:
 					err = fs.SkipField(tok)
 					if err != nil {
 						return fs.WrapErr(err)
@@ -1659,83 +2391,117 @@ mainparse:
 		}
 	}
 
-handle_Single:
+handle_Name:
 
-	/* handler: uj.Single type=[]*prometheus.ConstMetric kind=slice */
+	/* handler: j.Name type=string kind=string quoted=false*/
 
 	{
 
 		{
-			if tok != fflib.FFTok_left_brace && tok != fflib.FFTok_null {
-				return fs.WrapErr(fmt.Errorf("cannot unmarshal %s into Go value for ", tok))
+			if tok != fflib.FFTok_string && tok != fflib.FFTok_null {
+				return fs.WrapErr(fmt.Errorf("cannot unmarshal %s into Go value for string", tok))
 			}
 		}
 
 		if tok == fflib.FFTok_null {
-			uj.Single = nil
+
 		} else {
 
-			uj.Single = make([]*ConstMetric, 0)
+			outBuf := fs.Output.Bytes()
 
-			wantVal := true
+			j.Name = string(string(outBuf))
 
-			for {
+		}
+	}
 
-				var v *ConstMetric
+	state = fflib.FFParse_after_value
+	goto mainparse
 
-				tok = fs.Scan()
-				if tok == fflib.FFTok_error {
-					goto tokerror
-				}
-				if tok == fflib.FFTok_right_brace {
-					break
-				}
+handle_ValueType:
 
-				if tok == fflib.FFTok_comma {
-					if wantVal == true {
-						// TODO(pquerna): this isn't an ideal error message, this handles
-						// things like [,,,] as an array value.
-						return fs.WrapErr(fmt.Errorf("wanted value token, but got token: %v", tok))
-					}
-					continue
-				} else {
-					wantVal = true
-				}
+	/* handler: j.ValueType type=string kind=string quoted=false*/
 
-				/* handler: v type=*prometheus.ConstMetric kind=ptr */
+	{
 
-				{
-					if tok == fflib.FFTok_null {
+		{
+			if tok != fflib.FFTok_string && tok != fflib.FFTok_null {
+				return fs.WrapErr(fmt.Errorf("cannot unmarshal %s into Go value for string", tok))
+			}
+		}
 
-						v = nil
+		if tok == fflib.FFTok_null {
 
-						state = fflib.FFParse_after_value
-						goto mainparse
-					}
+		} else {
 
-					if v == nil {
-						v = new(ConstMetric)
-					}
+			outBuf := fs.Output.Bytes()
 
-					err = v.UnmarshalJSONFFLexer(fs, fflib.FFParse_want_key)
-					if err != nil {
-						return err
-					}
-					state = fflib.FFParse_after_value
-				}
+			j.ValueType = string(string(outBuf))
 
-				uj.Single = append(uj.Single, v)
-				wantVal = false
+		}
+	}
+
+	state = fflib.FFParse_after_value
+	goto mainparse
+
+handle_Help:
+
+	/* handler: j.Help type=string kind=string quoted=false*/
+
+	{
+
+		{
+			if tok != fflib.FFTok_string && tok != fflib.FFTok_null {
+				return fs.WrapErr(fmt.Errorf("cannot unmarshal %s into Go value for string", tok))
 			}
 		}
+
+		if tok == fflib.FFTok_null {
+
+		} else {
+
+			outBuf := fs.Output.Bytes()
+
+			j.Help = string(string(outBuf))
+
+		}
 	}
 
 	state = fflib.FFParse_after_value
 	goto mainparse
 
-handle_Summary:
+handle_Expires:
+
+	/* handler: j.Expires type=int64 kind=int64 quoted=false*/
+
+	{
+		if tok != fflib.FFTok_integer && tok != fflib.FFTok_null {
+			return fs.WrapErr(fmt.Errorf("cannot unmarshal %s into Go value for int64", tok))
+		}
+	}
+
+	{
+
+		if tok == fflib.FFTok_null {
+
+		} else {
+
+			tval, err := fflib.ParseInt(fs.Output.Bytes(), 10, 64)
+
+			if err != nil {
+				return fs.WrapErr(err)
+			}
+
+			j.Expires = int64(tval)
 
-	/* handler: uj.Summary type=[]*prometheus.ConstSummary kind=slice */
+		}
+	}
+
+	state = fflib.FFParse_after_value
+	goto mainparse
+
+handle_LabelNames:
+
+	/* handler: j.LabelNames type=[]string kind=slice quoted=false*/
 
 	{
 
@@ -1746,16 +2512,16 @@ handle_Summary:
 		}
 
 		if tok == fflib.FFTok_null {
-			uj.Summary = nil
+			j.LabelNames = nil
 		} else {
 
-			uj.Summary = make([]*ConstSummary, 0)
+			j.LabelNames = []string{}
 
 			wantVal := true
 
 			for {
 
-				var v *ConstSummary
+				var tmpJLabelNames string
 
 				tok = fs.Scan()
 				if tok == fflib.FFTok_error {
@@ -1776,29 +2542,29 @@ handle_Summary:
 					wantVal = true
 				}
 
-				/* handler: v type=*prometheus.ConstSummary kind=ptr */
+				/* handler: tmpJLabelNames type=string kind=string quoted=false*/
 
 				{
+
+					{
+						if tok != fflib.FFTok_string && tok != fflib.FFTok_null {
+							return fs.WrapErr(fmt.Errorf("cannot unmarshal %s into Go value for string", tok))
+						}
+					}
+
 					if tok == fflib.FFTok_null {
 
-						v = nil
+					} else {
 
-						state = fflib.FFParse_after_value
-						goto mainparse
-					}
+						outBuf := fs.Output.Bytes()
 
-					if v == nil {
-						v = new(ConstSummary)
-					}
+						tmpJLabelNames = string(string(outBuf))
 
-					err = v.UnmarshalJSONFFLexer(fs, fflib.FFParse_want_key)
-					if err != nil {
-						return err
 					}
-					state = fflib.FFParse_after_value
 				}
 
-				uj.Summary = append(uj.Summary, v)
+				j.LabelNames = append(j.LabelNames, tmpJLabelNames)
+
 				wantVal = false
 			}
 		}
@@ -1807,9 +2573,29 @@ handle_Summary:
 	state = fflib.FFParse_after_value
 	goto mainparse
 
-handle_Histogram:
+handle_LabelValues:
+
+	/* handler: j.LabelValues type=[][]string kind=slice quoted=false*/
+
+	{
+		/* Falling back. type=[][]string kind=slice */
+		tbuf, err := fs.CaptureField(tok)
+		if err != nil {
+			return fs.WrapErr(err)
+		}
+
+		err = json.Unmarshal(tbuf, &j.LabelValues)
+		if err != nil {
+			return fs.WrapErr(err)
+		}
+	}
+
+	state = fflib.FFParse_after_value
+	goto mainparse
+
+handle_Values:
 
-	/* handler: uj.Histogram type=[]*prometheus.ConstHistogram kind=slice */
+	/* handler: j.Values type=[]float64 kind=slice quoted=false*/
 
 	{
 
@@ -1820,16 +2606,16 @@ handle_Histogram:
 		}
 
 		if tok == fflib.FFTok_null {
-			uj.Histogram = nil
+			j.Values = nil
 		} else {
 
-			uj.Histogram = make([]*ConstHistogram, 0)
+			j.Values = []float64{}
 
 			wantVal := true
 
 			for {
 
-				var v *ConstHistogram
+				var tmpJValues float64
 
 				tok = fs.Scan()
 				if tok == fflib.FFTok_error {
@@ -1850,29 +2636,33 @@ handle_Histogram:
 					wantVal = true
 				}
 
-				/* handler: v type=*prometheus.ConstHistogram kind=ptr */
+				/* handler: tmpJValues type=float64 kind=float64 quoted=false*/
+
+				{
+					if tok != fflib.FFTok_double && tok != fflib.FFTok_integer && tok != fflib.FFTok_null {
+						return fs.WrapErr(fmt.Errorf("cannot unmarshal %s into Go value for float64", tok))
+					}
+				}
 
 				{
+
 					if tok == fflib.FFTok_null {
 
-						v = nil
+					} else {
 
-						state = fflib.FFParse_after_value
-						goto mainparse
-					}
+						tval, err := fflib.ParseFloat(fs.Output.Bytes(), 64)
 
-					if v == nil {
-						v = new(ConstHistogram)
-					}
+						if err != nil {
+							return fs.WrapErr(err)
+						}
+
+						tmpJValues = float64(tval)
 
-					err = v.UnmarshalJSONFFLexer(fs, fflib.FFParse_want_key)
-					if err != nil {
-						return err
 					}
-					state = fflib.FFParse_after_value
 				}
 
-				uj.Histogram = append(uj.Histogram, v)
+				j.Values = append(j.Values, tmpJValues)
+
 				wantVal = false
 			}
 		}
@@ -1895,5 +2685,1216 @@ tokerror:
 	}
 	panic("ffjson-generated: unreachable, please report bug.")
 done:
+
+	return nil
+}
+
+// MarshalJSON marshal bytes to json - template
+func (j *Metrics) MarshalJSON() ([]byte, error) {
+	var buf fflib.Buffer
+	if j == nil {
+		buf.WriteString("null")
+		return buf.Bytes(), nil
+	}
+	err := j.MarshalJSONBuf(&buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// MarshalJSONBuf marshal buff to json - template
+func (j *Metrics) MarshalJSONBuf(buf fflib.EncodingBuffer) error {
+	if j == nil {
+		buf.WriteString("null")
+		return nil
+	}
+	var err error
+	var obj []byte
+	_ = obj
+	_ = err
+	buf.WriteString(`{"Single":`)
+	if j.Single != nil {
+		buf.WriteString(`[`)
+		for i, v := range j.Single {
+			if i != 0 {
+				buf.WriteString(`,`)
+			}
+
+			{
+
+				if v == nil {
+					buf.WriteString("null")
+				} else {
+
+					err = v.MarshalJSONBuf(buf)
+					if err != nil {
+						return err
+					}
+
+				}
+
+			}
+		}
+		buf.WriteString(`]`)
+	} else {
+		buf.WriteString(`null`)
+	}
+	buf.WriteString(`,"Summary":`)
+	if j.Summary != nil {
+		buf.WriteString(`[`)
+		for i, v := range j.Summary {
+			if i != 0 {
+				buf.WriteString(`,`)
+			}
+
+			{
+
+				if v == nil {
+					buf.WriteString("null")
+				} else {
+
+					err = v.MarshalJSONBuf(buf)
+					if err != nil {
+						return err
+					}
+
+				}
+
+			}
+		}
+		buf.WriteString(`]`)
+	} else {
+		buf.WriteString(`null`)
+	}
+	buf.WriteString(`,"Histogram":`)
+	if j.Histogram != nil {
+		buf.WriteString(`[`)
+		for i, v := range j.Histogram {
+			if i != 0 {
+				buf.WriteString(`,`)
+			}
+
+			{
+
+				if v == nil {
+					buf.WriteString("null")
+				} else {
+
+					err = v.MarshalJSONBuf(buf)
+					if err != nil {
+						return err
+					}
+
+				}
+
+			}
+		}
+		buf.WriteString(`]`)
+	} else {
+		buf.WriteString(`null`)
+	}
+	buf.WriteString(`,"Vector":`)
+	if j.Vector != nil {
+		buf.WriteString(`[`)
+		for i, v := range j.Vector {
+			if i != 0 {
+				buf.WriteString(`,`)
+			}
+
+			{
+
+				if v == nil {
+					buf.WriteString("null")
+				} else {
+
+					err = v.MarshalJSONBuf(buf)
+					if err != nil {
+						return err
+					}
+
+				}
+
+			}
+		}
+		buf.WriteString(`]`)
+	} else {
+		buf.WriteString(`null`)
+	}
+	if j.Labels == nil {
+		buf.WriteString(`,"Labels":null`)
+	} else {
+		buf.WriteString(`,"Labels":{ `)
+		for key, value := range j.Labels {
+			fflib.WriteJsonString(buf, key)
+			buf.WriteString(`:`)
+			fflib.WriteJsonString(buf, string(value))
+			buf.WriteByte(',')
+		}
+		buf.Rewind(1)
+		buf.WriteByte('}')
+	}
+	buf.WriteString(`,"Help":`)
+	fflib.WriteJsonString(buf, string(j.Help))
+	buf.WriteString(`,"Expires":`)
+	fflib.FormatBits2(buf, uint64(j.Expires), 10, j.Expires < 0)
+	buf.WriteString(`,"ValueType":`)
+	fflib.WriteJsonString(buf, string(j.ValueType))
+	if j.Group == nil {
+		buf.WriteString(`,"Group":null`)
+	} else {
+		buf.WriteString(`,"Group":{ `)
+		for key, value := range j.Group {
+			fflib.WriteJsonString(buf, key)
+			buf.WriteString(`:`)
+			fflib.WriteJsonString(buf, string(value))
+			buf.WriteByte(',')
+		}
+		buf.Rewind(1)
+		buf.WriteByte('}')
+	}
+	buf.WriteString(`,"Producer":`)
+	fflib.WriteJsonString(buf, string(j.Producer))
+	buf.WriteString(`,"Heartbeat":`)
+	fflib.FormatBits2(buf, uint64(j.Heartbeat), 10, j.Heartbeat < 0)
+	if j.Goodbye {
+		buf.WriteString(`,"Goodbye":true`)
+	} else {
+		buf.WriteString(`,"Goodbye":false`)
+	}
+	buf.WriteByte('}')
+	return nil
+}
+
+const (
+	ffjtMetricsbase = iota
+	ffjtMetricsnosuchkey
+
+	ffjtMetricsSingle
+
+	ffjtMetricsSummary
+
+	ffjtMetricsHistogram
+
+	ffjtMetricsVector
+
+	ffjtMetricsLabels
+
+	ffjtMetricsHelp
+
+	ffjtMetricsExpires
+
+	ffjtMetricsValueType
+
+	ffjtMetricsGroup
+
+	ffjtMetricsProducer
+
+	ffjtMetricsHeartbeat
+
+	ffjtMetricsGoodbye
+)
+
+var ffjKeyMetricsSingle = []byte("Single")
+
+var ffjKeyMetricsSummary = []byte("Summary")
+
+var ffjKeyMetricsHistogram = []byte("Histogram")
+
+var ffjKeyMetricsVector = []byte("Vector")
+
+var ffjKeyMetricsLabels = []byte("Labels")
+
+var ffjKeyMetricsHelp = []byte("Help")
+
+var ffjKeyMetricsExpires = []byte("Expires")
+
+var ffjKeyMetricsValueType = []byte("ValueType")
+
+var ffjKeyMetricsGroup = []byte("Group")
+
+var ffjKeyMetricsProducer = []byte("Producer")
+
+var ffjKeyMetricsHeartbeat = []byte("Heartbeat")
+
+var ffjKeyMetricsGoodbye = []byte("Goodbye")
+
+// UnmarshalJSON umarshall json - template of ffjson
+func (j *Metrics) UnmarshalJSON(input []byte) error {
+	fs := fflib.NewFFLexer(input)
+	return j.UnmarshalJSONFFLexer(fs, fflib.FFParse_map_start)
+}
+
+// UnmarshalJSONFFLexer fast json unmarshall - template ffjson
+func (j *Metrics) UnmarshalJSONFFLexer(fs *fflib.FFLexer, state fflib.FFParseState) error {
+	var err error
+	currentKey := ffjtMetricsbase
+	_ = currentKey
+	tok := fflib.FFTok_init
+	wantedTok := fflib.FFTok_init
+
+mainparse:
+	for {
+		tok = fs.Scan()
+		//	println(fmt.Sprintf("debug: tok: %v  state: %v", tok, state))
+		if tok == fflib.FFTok_error {
+			goto tokerror
+		}
+
+		switch state {
+
+		case fflib.FFParse_map_start:
+			if tok != fflib.FFTok_left_bracket {
+				wantedTok = fflib.FFTok_left_bracket
+				goto wrongtokenerror
+			}
+			state = fflib.FFParse_want_key
+			continue
+
+		case fflib.FFParse_after_value:
+			if tok == fflib.FFTok_comma {
+				state = fflib.FFParse_want_key
+			} else if tok == fflib.FFTok_right_bracket {
+				goto done
+			} else {
+				wantedTok = fflib.FFTok_comma
+				goto wrongtokenerror
+			}
+
+		case fflib.FFParse_want_key:
+			// json {} ended. goto exit. woo.
+			if tok == fflib.FFTok_right_bracket {
+				goto done
+			}
+			if tok != fflib.FFTok_string {
+				wantedTok = fflib.FFTok_string
+				goto wrongtokenerror
+			}
+
+			kn := fs.Output.Bytes()
+			if len(kn) <= 0 {
+				// "" case. hrm.
+				currentKey = ffjtMetricsnosuchkey
+				state = fflib.FFParse_want_colon
+				goto mainparse
+			} else {
+				switch kn[0] {
+
+				case 'E':
+
+					if bytes.Equal(ffjKeyMetricsExpires, kn) {
+						currentKey = ffjtMetricsExpires
+						state = fflib.FFParse_want_colon
+						goto mainparse
+					}
+
+				case 'G':
+
+					if bytes.Equal(ffjKeyMetricsGroup, kn) {
+						currentKey = ffjtMetricsGroup
+						state = fflib.FFParse_want_colon
+						goto mainparse
+
+					} else if bytes.Equal(ffjKeyMetricsGoodbye, kn) {
+						currentKey = ffjtMetricsGoodbye
+						state = fflib.FFParse_want_colon
+						goto mainparse
+					}
+
+				case 'H':
+
+					if bytes.Equal(ffjKeyMetricsHistogram, kn) {
+						currentKey = ffjtMetricsHistogram
+						state = fflib.FFParse_want_colon
+						goto mainparse
+
+					} else if bytes.Equal(ffjKeyMetricsHelp, kn) {
+						currentKey = ffjtMetricsHelp
+						state = fflib.FFParse_want_colon
+						goto mainparse
+
+					} else if bytes.Equal(ffjKeyMetricsHeartbeat, kn) {
+						currentKey = ffjtMetricsHeartbeat
+						state = fflib.FFParse_want_colon
+						goto mainparse
+					}
+
+				case 'L':
+
+					if bytes.Equal(ffjKeyMetricsLabels, kn) {
+						currentKey = ffjtMetricsLabels
+						state = fflib.FFParse_want_colon
+						goto mainparse
+					}
+
+				case 'P':
+
+					if bytes.Equal(ffjKeyMetricsProducer, kn) {
+						currentKey = ffjtMetricsProducer
+						state = fflib.FFParse_want_colon
+						goto mainparse
+					}
+
+				case 'S':
+
+					if bytes.Equal(ffjKeyMetricsSingle, kn) {
+						currentKey = ffjtMetricsSingle
+						state = fflib.FFParse_want_colon
+						goto mainparse
+
+					} else if bytes.Equal(ffjKeyMetricsSummary, kn) {
+						currentKey = ffjtMetricsSummary
+						state = fflib.FFParse_want_colon
+						goto mainparse
+					}
+
+				case 'V':
+
+					if bytes.Equal(ffjKeyMetricsVector, kn) {
+						currentKey = ffjtMetricsVector
+						state = fflib.FFParse_want_colon
+						goto mainparse
+
+					} else if bytes.Equal(ffjKeyMetricsValueType, kn) {
+						currentKey = ffjtMetricsValueType
+						state = fflib.FFParse_want_colon
+						goto mainparse
+					}
+
+				}
+
+				if fflib.SimpleLetterEqualFold(ffjKeyMetricsGoodbye, kn) {
+					currentKey = ffjtMetricsGoodbye
+					state = fflib.FFParse_want_colon
+					goto mainparse
+				}
+
+				if fflib.SimpleLetterEqualFold(ffjKeyMetricsHeartbeat, kn) {
+					currentKey = ffjtMetricsHeartbeat
+					state = fflib.FFParse_want_colon
+					goto mainparse
+				}
+
+				if fflib.SimpleLetterEqualFold(ffjKeyMetricsProducer, kn) {
+					currentKey = ffjtMetricsProducer
+					state = fflib.FFParse_want_colon
+					goto mainparse
+				}
+
+				if fflib.SimpleLetterEqualFold(ffjKeyMetricsGroup, kn) {
+					currentKey = ffjtMetricsGroup
+					state = fflib.FFParse_want_colon
+					goto mainparse
+				}
+
+				if fflib.SimpleLetterEqualFold(ffjKeyMetricsValueType, kn) {
+					currentKey = ffjtMetricsValueType
+					state = fflib.FFParse_want_colon
+					goto mainparse
+				}
+
+				if fflib.EqualFoldRight(ffjKeyMetricsExpires, kn) {
+					currentKey = ffjtMetricsExpires
+					state = fflib.FFParse_want_colon
+					goto mainparse
+				}
+
+				if fflib.SimpleLetterEqualFold(ffjKeyMetricsHelp, kn) {
+					currentKey = ffjtMetricsHelp
+					state = fflib.FFParse_want_colon
+					goto mainparse
+				}
+
+				if fflib.EqualFoldRight(ffjKeyMetricsLabels, kn) {
+					currentKey = ffjtMetricsLabels
+					state = fflib.FFParse_want_colon
+					goto mainparse
+				}
+
+				if fflib.SimpleLetterEqualFold(ffjKeyMetricsVector, kn) {
+					currentKey = ffjtMetricsVector
+					state = fflib.FFParse_want_colon
+					goto mainparse
+				}
+
+				if fflib.EqualFoldRight(ffjKeyMetricsHistogram, kn) {
+					currentKey = ffjtMetricsHistogram
+					state = fflib.FFParse_want_colon
+					goto mainparse
+				}
+
+				if fflib.EqualFoldRight(ffjKeyMetricsSummary, kn) {
+					currentKey = ffjtMetricsSummary
+					state = fflib.FFParse_want_colon
+					goto mainparse
+				}
+
+				if fflib.EqualFoldRight(ffjKeyMetricsSingle, kn) {
+					currentKey = ffjtMetricsSingle
+					state = fflib.FFParse_want_colon
+					goto mainparse
+				}
+
+				currentKey = ffjtMetricsnosuchkey
+				state = fflib.FFParse_want_colon
+				goto mainparse
+			}
+
+		case fflib.FFParse_want_colon:
+			if tok != fflib.FFTok_colon {
+				wantedTok = fflib.FFTok_colon
+				goto wrongtokenerror
+			}
+			state = fflib.FFParse_want_value
+			continue
+		case fflib.FFParse_want_value:
+
+			if tok == fflib.FFTok_left_brace || tok == fflib.FFTok_left_bracket || tok == fflib.FFTok_integer || tok == fflib.FFTok_double || tok == fflib.FFTok_string || tok == fflib.FFTok_bool || tok == fflib.FFTok_null {
+				switch currentKey {
+
+				case ffjtMetricsSingle:
+					goto handle_Single
+
+				case ffjtMetricsSummary:
+					goto handle_Summary
+
+				case ffjtMetricsHistogram:
+					goto handle_Histogram
+
+				case ffjtMetricsVector:
+					goto handle_Vector
+
+				case ffjtMetricsLabels:
+					goto handle_Labels
+
+				case ffjtMetricsHelp:
+					goto handle_Help
+
+				case ffjtMetricsExpires:
+					goto handle_Expires
+
+				case ffjtMetricsValueType:
+					goto handle_ValueType
+
+				case ffjtMetricsGroup:
+					goto handle_Group
+
+				case ffjtMetricsProducer:
+					goto handle_Producer
+
+				case ffjtMetricsHeartbeat:
+					goto handle_Heartbeat
+
+				case ffjtMetricsGoodbye:
+					goto handle_Goodbye
+
+				case ffjtMetricsnosuchkey:
+					err = fs.SkipField(tok)
+					if err != nil {
+						return fs.WrapErr(err)
+					}
+					state = fflib.FFParse_after_value
+					goto mainparse
+				}
+			} else {
+				goto wantedvalue
+			}
+		}
+	}
+
+handle_Single:
+
+	/* handler: j.Single type=[]*prometheus.ConstMetric kind=slice quoted=false*/
+
+	{
+
+		{
+			if tok != fflib.FFTok_left_brace && tok != fflib.FFTok_null {
+				return fs.WrapErr(fmt.Errorf("cannot unmarshal %s into Go value for ", tok))
+			}
+		}
+
+		if tok == fflib.FFTok_null {
+			j.Single = nil
+		} else {
+
+			j.Single = []*ConstMetric{}
+
+			wantVal := true
+
+			for {
+
+				var tmpJSingle *ConstMetric
+
+				tok = fs.Scan()
+				if tok == fflib.FFTok_error {
+					goto tokerror
+				}
+				if tok == fflib.FFTok_right_brace {
+					break
+				}
+
+				if tok == fflib.FFTok_comma {
+					if wantVal == true {
+						// TODO(pquerna): this isn't an ideal error message, this handles
+						// things like [,,,] as an array value.
+						return fs.WrapErr(fmt.Errorf("wanted value token, but got token: %v", tok))
+					}
+					continue
+				} else {
+					wantVal = true
+				}
+
+				/* handler: tmpJSingle type=*prometheus.ConstMetric kind=ptr quoted=false*/
+
+				{
+					if tok == fflib.FFTok_null {
+
+						tmpJSingle = nil
+
+					} else {
+
+						if tmpJSingle == nil {
+							tmpJSingle = new(ConstMetric)
+						}
+
+						err = tmpJSingle.UnmarshalJSONFFLexer(fs, fflib.FFParse_want_key)
+						if err != nil {
+							return err
+						}
+					}
+					state = fflib.FFParse_after_value
+				}
+
+				j.Single = append(j.Single, tmpJSingle)
+
+				wantVal = false
+			}
+		}
+	}
+
+	state = fflib.FFParse_after_value
+	goto mainparse
+
+handle_Summary:
+
+	/* handler: j.Summary type=[]*prometheus.ConstSummary kind=slice quoted=false*/
+
+	{
+
+		{
+			if tok != fflib.FFTok_left_brace && tok != fflib.FFTok_null {
+				return fs.WrapErr(fmt.Errorf("cannot unmarshal %s into Go value for ", tok))
+			}
+		}
+
+		if tok == fflib.FFTok_null {
+			j.Summary = nil
+		} else {
+
+			j.Summary = []*ConstSummary{}
+
+			wantVal := true
+
+			for {
+
+				var tmpJSummary *ConstSummary
+
+				tok = fs.Scan()
+				if tok == fflib.FFTok_error {
+					goto tokerror
+				}
+				if tok == fflib.FFTok_right_brace {
+					break
+				}
+
+				if tok == fflib.FFTok_comma {
+					if wantVal == true {
+						// TODO(pquerna): this isn't an ideal error message, this handles
+						// things like [,,,] as an array value.
+						return fs.WrapErr(fmt.Errorf("wanted value token, but got token: %v", tok))
+					}
+					continue
+				} else {
+					wantVal = true
+				}
+
+				/* handler: tmpJSummary type=*prometheus.ConstSummary kind=ptr quoted=false*/
+
+				{
+					if tok == fflib.FFTok_null {
+
+						tmpJSummary = nil
+
+					} else {
+
+						if tmpJSummary == nil {
+							tmpJSummary = new(ConstSummary)
+						}
+
+						err = tmpJSummary.UnmarshalJSONFFLexer(fs, fflib.FFParse_want_key)
+						if err != nil {
+							return err
+						}
+					}
+					state = fflib.FFParse_after_value
+				}
+
+				j.Summary = append(j.Summary, tmpJSummary)
+
+				wantVal = false
+			}
+		}
+	}
+
+	state = fflib.FFParse_after_value
+	goto mainparse
+
+handle_Histogram:
+
+	/* handler: j.Histogram type=[]*prometheus.ConstHistogram kind=slice quoted=false*/
+
+	{
+
+		{
+			if tok != fflib.FFTok_left_brace && tok != fflib.FFTok_null {
+				return fs.WrapErr(fmt.Errorf("cannot unmarshal %s into Go value for ", tok))
+			}
+		}
+
+		if tok == fflib.FFTok_null {
+			j.Histogram = nil
+		} else {
+
+			j.Histogram = []*ConstHistogram{}
+
+			wantVal := true
+
+			for {
+
+				var tmpJHistogram *ConstHistogram
+
+				tok = fs.Scan()
+				if tok == fflib.FFTok_error {
+					goto tokerror
+				}
+				if tok == fflib.FFTok_right_brace {
+					break
+				}
+
+				if tok == fflib.FFTok_comma {
+					if wantVal == true {
+						// TODO(pquerna): this isn't an ideal error message, this handles
+						// things like [,,,] as an array value.
+						return fs.WrapErr(fmt.Errorf("wanted value token, but got token: %v", tok))
+					}
+					continue
+				} else {
+					wantVal = true
+				}
+
+				/* handler: tmpJHistogram type=*prometheus.ConstHistogram kind=ptr quoted=false*/
+
+				{
+					if tok == fflib.FFTok_null {
+
+						tmpJHistogram = nil
+
+					} else {
+
+						if tmpJHistogram == nil {
+							tmpJHistogram = new(ConstHistogram)
+						}
+
+						err = tmpJHistogram.UnmarshalJSONFFLexer(fs, fflib.FFParse_want_key)
+						if err != nil {
+							return err
+						}
+					}
+					state = fflib.FFParse_after_value
+				}
+
+				j.Histogram = append(j.Histogram, tmpJHistogram)
+
+				wantVal = false
+			}
+		}
+	}
+
+	state = fflib.FFParse_after_value
+	goto mainparse
+
+handle_Vector:
+
+	/* handler: j.Vector type=[]*prometheus.ConstVector kind=slice quoted=false*/
+
+	{
+
+		{
+			if tok != fflib.FFTok_left_brace && tok != fflib.FFTok_null {
+				return fs.WrapErr(fmt.Errorf("cannot unmarshal %s into Go value for ", tok))
+			}
+		}
+
+		if tok == fflib.FFTok_null {
+			j.Vector = nil
+		} else {
+
+			j.Vector = []*ConstVector{}
+
+			wantVal := true
+
+			for {
+
+				var tmpJVector *ConstVector
+
+				tok = fs.Scan()
+				if tok == fflib.FFTok_error {
+					goto tokerror
+				}
+				if tok == fflib.FFTok_right_brace {
+					break
+				}
+
+				if tok == fflib.FFTok_comma {
+					if wantVal == true {
+						// TODO(pquerna): this isn't an ideal error message, this handles
+						// things like [,,,] as an array value.
+						return fs.WrapErr(fmt.Errorf("wanted value token, but got token: %v", tok))
+					}
+					continue
+				} else {
+					wantVal = true
+				}
+
+				/* handler: tmpJVector type=*prometheus.ConstVector kind=ptr quoted=false*/
+
+				{
+					if tok == fflib.FFTok_null {
+
+						tmpJVector = nil
+
+					} else {
+
+						if tmpJVector == nil {
+							tmpJVector = new(ConstVector)
+						}
+
+						err = tmpJVector.UnmarshalJSONFFLexer(fs, fflib.FFParse_want_key)
+						if err != nil {
+							return err
+						}
+					}
+					state = fflib.FFParse_after_value
+				}
+
+				j.Vector = append(j.Vector, tmpJVector)
+
+				wantVal = false
+			}
+		}
+	}
+
+	state = fflib.FFParse_after_value
+	goto mainparse
+
+handle_Labels:
+
+	/* handler: j.Labels type=map[string]string kind=map quoted=false*/
+
+	{
+
+		{
+			if tok != fflib.FFTok_left_bracket && tok != fflib.FFTok_null {
+				return fs.WrapErr(fmt.Errorf("cannot unmarshal %s into Go value for ", tok))
+			}
+		}
+
+		if tok == fflib.FFTok_null {
+			j.Labels = nil
+		} else {
+
+			j.Labels = make(map[string]string, 0)
+
+			wantVal := true
+
+			for {
+
+				var k string
+
+				var tmpJLabels string
+
+				tok = fs.Scan()
+				if tok == fflib.FFTok_error {
+					goto tokerror
+				}
+				if tok == fflib.FFTok_right_bracket {
+					break
+				}
+
+				if tok == fflib.FFTok_comma {
+					if wantVal == true {
+						// TODO(pquerna): this isn't an ideal error message, this handles
+						// things like [,,,] as an array value.
+						return fs.WrapErr(fmt.Errorf("wanted value token, but got token: %v", tok))
+					}
+					continue
+				} else {
+					wantVal = true
+				}
+
+				/* handler: k type=string kind=string quoted=false*/
+
+				{
+
+					{
+						if tok != fflib.FFTok_string && tok != fflib.FFTok_null {
+							return fs.WrapErr(fmt.Errorf("cannot unmarshal %s into Go value for string", tok))
+						}
+					}
+
+					if tok == fflib.FFTok_null {
+
+					} else {
+
+						outBuf := fs.Output.Bytes()
+
+						k = string(string(outBuf))
+
+					}
+				}
+
+				// Expect ':' after key
+				tok = fs.Scan()
+				if tok != fflib.FFTok_colon {
+					return fs.WrapErr(fmt.Errorf("wanted colon token, but got token: %v", tok))
+				}
+
+				tok = fs.Scan()
+				/* handler: tmpJLabels type=string kind=string quoted=false*/
+
+				{
+
+					{
+						if tok != fflib.FFTok_string && tok != fflib.FFTok_null {
+							return fs.WrapErr(fmt.Errorf("cannot unmarshal %s into Go value for string", tok))
+						}
+					}
+
+					if tok == fflib.FFTok_null {
+
+					} else {
+
+						outBuf := fs.Output.Bytes()
+
+						tmpJLabels = string(string(outBuf))
+
+					}
+				}
+
+				j.Labels[k] = tmpJLabels
+
+				wantVal = false
+			}
+
+		}
+	}
+
+	state = fflib.FFParse_after_value
+	goto mainparse
+
+handle_Help:
+
+	/* handler: j.Help type=string kind=string quoted=false*/
+
+	{
+
+		{
+			if tok != fflib.FFTok_string && tok != fflib.FFTok_null {
+				return fs.WrapErr(fmt.Errorf("cannot unmarshal %s into Go value for string", tok))
+			}
+		}
+
+		if tok == fflib.FFTok_null {
+
+		} else {
+
+			outBuf := fs.Output.Bytes()
+
+			j.Help = string(string(outBuf))
+
+		}
+	}
+
+	state = fflib.FFParse_after_value
+	goto mainparse
+
+handle_Expires:
+
+	/* handler: j.Expires type=int64 kind=int64 quoted=false*/
+
+	{
+		if tok != fflib.FFTok_integer && tok != fflib.FFTok_null {
+			return fs.WrapErr(fmt.Errorf("cannot unmarshal %s into Go value for int64", tok))
+		}
+	}
+
+	{
+
+		if tok == fflib.FFTok_null {
+
+		} else {
+
+			tval, err := fflib.ParseInt(fs.Output.Bytes(), 10, 64)
+
+			if err != nil {
+				return fs.WrapErr(err)
+			}
+
+			j.Expires = int64(tval)
+
+		}
+	}
+
+	state = fflib.FFParse_after_value
+	goto mainparse
+
+handle_ValueType:
+
+	/* handler: j.ValueType type=string kind=string quoted=false*/
+
+	{
+
+		{
+			if tok != fflib.FFTok_string && tok != fflib.FFTok_null {
+				return fs.WrapErr(fmt.Errorf("cannot unmarshal %s into Go value for string", tok))
+			}
+		}
+
+		if tok == fflib.FFTok_null {
+
+		} else {
+
+			outBuf := fs.Output.Bytes()
+
+			j.ValueType = string(string(outBuf))
+
+		}
+	}
+
+	state = fflib.FFParse_after_value
+	goto mainparse
+
+handle_Group:
+
+	/* handler: j.Group type=map[string]string kind=map quoted=false*/
+
+	{
+
+		{
+			if tok != fflib.FFTok_left_bracket && tok != fflib.FFTok_null {
+				return fs.WrapErr(fmt.Errorf("cannot unmarshal %s into Go value for ", tok))
+			}
+		}
+
+		if tok == fflib.FFTok_null {
+			j.Group = nil
+		} else {
+
+			j.Group = make(map[string]string, 0)
+
+			wantVal := true
+
+			for {
+
+				var k string
+
+				var tmpJGroup string
+
+				tok = fs.Scan()
+				if tok == fflib.FFTok_error {
+					goto tokerror
+				}
+				if tok == fflib.FFTok_right_bracket {
+					break
+				}
+
+				if tok == fflib.FFTok_comma {
+					if wantVal == true {
+						// TODO(pquerna): this isn't an ideal error message, this handles
+						// things like [,,,] as an array value.
+						return fs.WrapErr(fmt.Errorf("wanted value token, but got token: %v", tok))
+					}
+					continue
+				} else {
+					wantVal = true
+				}
+
+				/* handler: k type=string kind=string quoted=false*/
+
+				{
+
+					{
+						if tok != fflib.FFTok_string && tok != fflib.FFTok_null {
+							return fs.WrapErr(fmt.Errorf("cannot unmarshal %s into Go value for string", tok))
+						}
+					}
+
+					if tok == fflib.FFTok_null {
+
+					} else {
+
+						outBuf := fs.Output.Bytes()
+
+						k = string(string(outBuf))
+
+					}
+				}
+
+				// Expect ':' after key
+				tok = fs.Scan()
+				if tok != fflib.FFTok_colon {
+					return fs.WrapErr(fmt.Errorf("wanted colon token, but got token: %v", tok))
+				}
+
+				tok = fs.Scan()
+				/* handler: tmpJGroup type=string kind=string quoted=false*/
+
+				{
+
+					{
+						if tok != fflib.FFTok_string && tok != fflib.FFTok_null {
+							return fs.WrapErr(fmt.Errorf("cannot unmarshal %s into Go value for string", tok))
+						}
+					}
+
+					if tok == fflib.FFTok_null {
+
+					} else {
+
+						outBuf := fs.Output.Bytes()
+
+						tmpJGroup = string(string(outBuf))
+
+					}
+				}
+
+				j.Group[k] = tmpJGroup
+
+				wantVal = false
+			}
+
+		}
+	}
+
+	state = fflib.FFParse_after_value
+	goto mainparse
+
+handle_Producer:
+
+	/* handler: j.Producer type=string kind=string quoted=false*/
+
+	{
+
+		{
+			if tok != fflib.FFTok_string && tok != fflib.FFTok_null {
+				return fs.WrapErr(fmt.Errorf("cannot unmarshal %s into Go value for string", tok))
+			}
+		}
+
+		if tok == fflib.FFTok_null {
+
+		} else {
+
+			outBuf := fs.Output.Bytes()
+
+			j.Producer = string(string(outBuf))
+
+		}
+	}
+
+	state = fflib.FFParse_after_value
+	goto mainparse
+
+handle_Heartbeat:
+
+	/* handler: j.Heartbeat type=int64 kind=int64 quoted=false*/
+
+	{
+		if tok != fflib.FFTok_integer && tok != fflib.FFTok_null {
+			return fs.WrapErr(fmt.Errorf("cannot unmarshal %s into Go value for int64", tok))
+		}
+	}
+
+	{
+
+		if tok == fflib.FFTok_null {
+
+		} else {
+
+			tval, err := fflib.ParseInt(fs.Output.Bytes(), 10, 64)
+
+			if err != nil {
+				return fs.WrapErr(err)
+			}
+
+			j.Heartbeat = int64(tval)
+
+		}
+	}
+
+	state = fflib.FFParse_after_value
+	goto mainparse
+
+handle_Goodbye:
+
+	/* handler: j.Goodbye type=bool kind=bool quoted=false*/
+
+	{
+		if tok != fflib.FFTok_bool && tok != fflib.FFTok_null {
+			return fs.WrapErr(fmt.Errorf("cannot unmarshal %s into Go value for bool", tok))
+		}
+	}
+
+	{
+		if tok == fflib.FFTok_null {
+
+		} else {
+			tmpb := fs.Output.Bytes()
+
+			if bytes.Compare([]byte{'t', 'r', 'u', 'e'}, tmpb) == 0 {
+
+				j.Goodbye = true
+
+			} else if bytes.Compare([]byte{'f', 'a', 'l', 's', 'e'}, tmpb) == 0 {
+
+				j.Goodbye = false
+
+			} else {
+				err = errors.New("unexpected bytes for true/false value")
+				return fs.WrapErr(err)
+			}
+
+		}
+	}
+
+	state = fflib.FFParse_after_value
+	goto mainparse
+
+wantedvalue:
+	return fs.WrapErr(fmt.Errorf("wanted value token, but got token: %v", tok))
+wrongtokenerror:
+	return fs.WrapErr(fmt.Errorf("ffjson: wanted token: %v, but got token: %v output=%s", wantedTok, tok, fs.Output.String()))
+tokerror:
+	if fs.BigError != nil {
+		return fs.WrapErr(fs.BigError)
+	}
+	err = fs.Error.ToError()
+	if err != nil {
+		return fs.WrapErr(err)
+	}
+	panic("ffjson-generated: unreachable, please report bug.")
+done:
+
 	return nil
 }
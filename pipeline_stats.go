@@ -0,0 +1,124 @@
+package prometheus
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/mozilla-services/heka/pipeline"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// allReportType is the type of the message hekad injects when asked for a
+// report on every running plugin, the same data its dashboard shows.
+const allReportType = "heka.all-report"
+
+// pipelineSections maps the sections of an all-report to the kind label of
+// the plugins listed in them.
+var pipelineSections = map[string]string{
+	"globals":  "global",
+	"inputs":   "input",
+	"decoders": "decoder",
+	"filters":  "filter",
+	"outputs":  "output",
+	"encoders": "encoder",
+}
+
+type reportValue struct {
+	Value          interface{} `json:"value"`
+	Representation string      `json:"representation"`
+}
+
+// requestReports asks hekad for an all-report every interval until stop is
+// closed. The report comes back through the router like any other message,
+// so message_matcher has to let heka.all-report through, and every other
+// output matching it receives the report as well.
+func requestReports(pc *pipeline.PipelineConfig, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			pc.AllReportsMsg()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// pipelineSamples turns the payload of an all-report into samples: every
+// numeric value reported for a plugin becomes heka_<value> labelled with the
+// plugin's name and kind. Values ending in Count are counters, the rest are
+// gauges. Durations, reported in nanoseconds, are exposed in seconds as
+// heka_<value>_seconds.
+func pipelineSamples(payload []byte, ttl time.Duration, timestamp, base time.Time) ([]*hekaSample, error) {
+	var report map[string][]map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &report); err != nil {
+		return nil, fmt.Errorf("%s: %v", allReportType, err)
+	}
+
+	var hsamples []*hekaSample
+	for section, plugins := range report {
+		kind, ok := pipelineSections[section]
+		if !ok {
+			continue
+		}
+		for _, fields := range plugins {
+			var name string
+			if err := json.Unmarshal(fields["Name"], &name); err != nil {
+				continue
+			}
+			for field, raw := range fields {
+				var v reportValue
+				if field == "Name" || json.Unmarshal(raw, &v) != nil {
+					continue
+				}
+				value, ok := v.Value.(float64)
+				if !ok {
+					continue
+				}
+
+				metric := "heka_" + snakeCase(field)
+				if strings.HasSuffix(field, "Duration") {
+					metric += "_seconds"
+					value /= 1e9
+				}
+
+				c := &ConstMetric{
+					Name:      metric,
+					Value:     value,
+					Help:      fmt.Sprintf("%s as reported by hekad", field),
+					Labels:    map[string]string{"plugin": name, "kind": kind},
+					valueType: prometheus.GaugeValue,
+				}
+				if strings.HasSuffix(field, "Count") {
+					c.valueType = prometheus.CounterValue
+				}
+				hsamples = append(hsamples, newSingleSample(c, ttl, timestamp, base))
+			}
+		}
+	}
+	return hsamples, nil
+}
+
+// snakeCase turns heka's CamelCase report fields into metric name parts,
+// e.g. InChanLength into in_chan_length.
+func snakeCase(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		switch {
+		case unicode.IsUpper(r):
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			b.WriteRune(unicode.ToLower(r))
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
@@ -15,6 +15,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -22,12 +23,40 @@ import (
 )
 
 type hekaSample struct {
+	// desc is looked up when the sample is stored.
 	desc      *prometheus.Desc
 	single    *ConstMetric
 	hist      *ConstHistogram
 	summ      *ConstSummary
 	valueType prometheus.ValueType
 	expires   time.Time
+	timestamp time.Time
+
+	// key and index locate the sample in its shard of PromOut.samples and in
+	// that shard's expiry heap.
+	key   uint64
+	index int
+
+	// group is the grouping key of the document the sample came in and
+	// producer the producer owning it.
+	group    string
+	producer string
+
+	// Samples without a metric carry instructions instead: groupEnd closes
+	// the inventory of a group, extend pushes out the expiry of every series
+	// of a producer and goodbye drops them.
+	groupEnd bool
+	extend   time.Time
+	goodbye  bool
+
+	// accumulate marks samples derived by a rule, they only carry what one
+	// message added and are merged into the stored sample of their series.
+	accumulate bool
+
+	// created is only set on counters that have been seen to reset, it is
+	// exposed as <name>_created when emit_created is on.
+	created     time.Time
+	createdDesc *prometheus.Desc
 }
 
 func expires(supplied int64, defaultTTL time.Duration, timestamp time.Time) time.Time {
@@ -47,7 +76,151 @@ func expires(supplied int64, defaultTTL time.Duration, timestamp time.Time) time
 
 }
 
-func newHekaSampleScalar(payload []byte, defaultTTL time.Duration, timestamp time.Time) ([]*hekaSample, error) {
+func newSingleSample(c *ConstMetric, defaultTTL time.Duration, timestamp, base time.Time) *hekaSample {
+	return &hekaSample{
+		single:    c,
+		expires:   expires(c.Expires, defaultTTL, base),
+		timestamp: timestamp,
+	}
+}
+
+// infoMetric turns an info metric into the gauge prometheus expects: the name
+// carries an _info suffix and the value is always 1, the information itself
+// lives in the labels.
+func infoMetric(c *ConstMetric) *ConstMetric {
+	if !strings.HasSuffix(c.Name, "_info") {
+		c.Name += "_info"
+	}
+	c.Value = 1
+	c.valueType = prometheus.GaugeValue
+	return c
+}
+
+// stateSetMetrics expands a stateset into one gauge per state, labelled with
+// the metric name and valued 1 for the active states and 0 for the rest.
+func stateSetMetrics(c *ConstMetric) ([]*ConstMetric, error) {
+	if len(c.States) == 0 {
+		return nil, fmt.Errorf("stateset %q has no states", c.Name)
+	}
+	if _, ok := c.Labels[c.Name]; ok {
+		return nil, fmt.Errorf(
+			"stateset %q already has a label named after the metric", c.Name,
+		)
+	}
+	metrics := make([]*ConstMetric, 0, len(c.States))
+	for state, on := range c.States {
+		labels := make(map[string]string, len(c.Labels)+1)
+		for k, v := range c.Labels {
+			labels[k] = v
+		}
+		labels[c.Name] = state
+
+		m := *c
+		m.Labels = labels
+		m.Value = 0
+		if on {
+			m.Value = 1
+		}
+		m.valueType = prometheus.GaugeValue
+		metrics = append(metrics, &m)
+	}
+	return metrics, nil
+}
+
+// expandVectors appends the series of every vector to the single entries.
+func (m *Metrics) expandVectors() error {
+	for _, v := range m.Vector {
+		if len(v.LabelValues) != len(v.Values) {
+			return fmt.Errorf(
+				"vector %q has %d label value tuples for %d values",
+				v.Name, len(v.LabelValues), len(v.Values),
+			)
+		}
+		for i, value := range v.Values {
+			if len(v.LabelValues[i]) != len(v.LabelNames) {
+				return fmt.Errorf(
+					"vector %q: series %d has %d label values for %d label names",
+					v.Name, i, len(v.LabelValues[i]), len(v.LabelNames),
+				)
+			}
+			labels := make(map[string]string, len(v.LabelNames))
+			for j, l := range v.LabelNames {
+				labels[l] = v.LabelValues[i][j]
+			}
+			m.Single = append(m.Single, &ConstMetric{
+				Value:     value,
+				ValueType: v.ValueType,
+				Name:      v.Name,
+				Labels:    labels,
+				Help:      v.Help,
+				Expires:   v.Expires,
+			})
+		}
+	}
+	return nil
+}
+
+// applyDefaults hands the document level labels, help, expires and
+// valuetype down to the entries that leave them out. Group labels override
+// the entries' own, envelope labels read off the message give way to both.
+func (m *Metrics) applyDefaults(envelope map[string]string) {
+	for _, c := range m.Single {
+		c.Labels = m.labels(c.Labels, envelope)
+		if c.Help == "" {
+			c.Help = m.Help
+		}
+		if c.Expires == 0 {
+			c.Expires = m.Expires
+		}
+		if c.ValueType == "" {
+			c.ValueType = m.ValueType
+		}
+	}
+	for _, c := range m.Summary {
+		c.Labels = m.labels(c.Labels, envelope)
+		if c.Help == "" {
+			c.Help = m.Help
+		}
+		if c.Expires == 0 {
+			c.Expires = m.Expires
+		}
+	}
+	for _, c := range m.Histogram {
+		c.Labels = m.labels(c.Labels, envelope)
+		if c.Help == "" {
+			c.Help = m.Help
+		}
+		if c.Expires == 0 {
+			c.Expires = m.Expires
+		}
+	}
+}
+
+// labels returns the labels of an entry carrying own.
+func (m *Metrics) labels(own, envelope map[string]string) map[string]string {
+	labels := mergeLabels(mergeLabels(envelope, m.Labels), own)
+	if len(m.Group) == 0 {
+		return labels
+	}
+	merged := make(map[string]string, len(labels)+len(m.Group))
+	for k, v := range labels {
+		merged[k] = v
+	}
+	for k, v := range m.Group {
+		merged[k] = v
+	}
+	return merged
+}
+
+// newHekaSampleScalar decodes a payload into samples stamped with the message
+// timestamp, their expiry counts from base.
+func newHekaSampleScalar(payload []byte, registry *metricRegistry, defaultTTL time.Duration, timestamp, base time.Time) ([]*hekaSample, error) {
+	return newHekaSamples(payload, nil, registry, defaultTTL, timestamp, base)
+}
+
+// newHekaSamples is newHekaSampleScalar putting envelope labels on every
+// sample before the registry gets to check them.
+func newHekaSamples(payload []byte, envelope map[string]string, registry *metricRegistry, defaultTTL time.Duration, timestamp, base time.Time) ([]*hekaSample, error) {
 	var (
 		cmetrics Metrics
 		err      error
@@ -57,15 +230,15 @@ func newHekaSampleScalar(payload []byte, defaultTTL time.Duration, timestamp tim
 	if err = ffjson.Unmarshal(payload, &cmetrics); err != nil {
 		return hsamples, err
 	}
+	if err = cmetrics.expandVectors(); err != nil {
+		return nil, err
+	}
+	cmetrics.applyDefaults(envelope)
 	for _, c := range cmetrics.Single {
-		h := &hekaSample{
-			single: c,
-			desc: prometheus.NewDesc(
-				c.Name, c.Help, []string{},
-				c.Labels,
-			),
-			expires: expires(c.Expires, defaultTTL, timestamp),
+		if err = registry.single(c); err != nil {
+			return nil, err
 		}
+		ttl := registry.ttl(c.Name, defaultTTL)
 
 		switch strings.ToLower(c.ValueType) {
 
@@ -73,22 +246,45 @@ func newHekaSampleScalar(payload []byte, defaultTTL time.Duration, timestamp tim
 			c.valueType = prometheus.GaugeValue
 		case "counter":
 			c.valueType = prometheus.CounterValue
-		default:
+		case "untyped":
 			c.valueType = prometheus.UntypedValue
+		case "info":
+			// the TTL of the exposed name wins over the one of the name sent
+			m := infoMetric(c)
+			hsamples = append(hsamples, newSingleSample(
+				m, registry.ttl(m.Name, ttl), timestamp, base,
+			))
+			continue
+		case "stateset":
+			states, err := stateSetMetrics(c)
+			if err != nil {
+				return nil, err
+			}
+			for _, s := range states {
+				hsamples = append(hsamples, newSingleSample(
+					s, ttl, timestamp, base,
+				))
+			}
+			continue
+		default:
+			return nil, fmt.Errorf(
+				"unknown valuetype %q for metric %q", c.ValueType, c.Name,
+			)
 		}
-		hsamples = append(hsamples, h)
+		hsamples = append(hsamples, newSingleSample(c, ttl, timestamp, base))
 	}
 	var f float64
 	for _, c := range cmetrics.Summary {
+		if err = registry.summary(c); err != nil {
+			return nil, err
+		}
 		c._quantiles = make(map[float64]float64)
 		h := &hekaSample{
 			summ: c,
-			desc: prometheus.NewDesc(
-				c.Name, c.Help, []string{},
-				c.Labels,
+			expires: expires(
+				c.Expires, registry.ttl(c.Name, defaultTTL), base,
 			),
-
-			expires: expires(c.Expires, defaultTTL, timestamp),
+			timestamp: timestamp,
 		}
 
 		for k, v := range c.Quantiles {
@@ -113,54 +309,229 @@ func newHekaSampleScalar(payload []byte, defaultTTL time.Duration, timestamp tim
 			}
 			c._buckets[f] = v
 		}
+		if err = registry.histogram(c); err != nil {
+			return nil, err
+		}
 
 		h := &hekaSample{
 			hist: c,
-			desc: prometheus.NewDesc(
-				c.Name, c.Help, []string{},
-				c.Labels,
+			expires: expires(
+				c.Expires, registry.ttl(c.Name, defaultTTL), base,
 			),
-			expires: expires(c.Expires, defaultTTL, timestamp),
+			timestamp: timestamp,
 		}
 		hsamples = append(hsamples, h)
 
 	}
 
+	for _, h := range hsamples {
+		h.producer = cmetrics.Producer
+	}
+	if cmetrics.Heartbeat > 0 {
+		hsamples = append(hsamples, &hekaSample{
+			producer: cmetrics.Producer,
+			extend:   expires(cmetrics.Heartbeat, 0, base),
+		})
+	}
+	if cmetrics.Goodbye {
+		// goodbye drops what was stored before, not the entries riding along
+		hsamples = append([]*hekaSample{{
+			producer: cmetrics.Producer,
+			goodbye:  true,
+		}}, hsamples...)
+	}
+
+	if len(cmetrics.Group) > 0 {
+		group := groupKey(cmetrics.Group)
+		for _, h := range hsamples {
+			h.group = group
+		}
+		hsamples = append(hsamples, &hekaSample{
+			group:    group,
+			groupEnd: true,
+			expires:  expires(0, defaultTTL, base),
+		})
+	}
 	return hsamples, nil
 }
 
 type PromOutConfig struct {
 	Address    string
 	DefaultTTL string `toml:"default_ttl"`
+	// EmitCreated exposes <name>_created for counters that went backwards,
+	// holding the timestamp of the message that carried the reset.
+	EmitCreated bool `toml:"emit_created"`
+
+	// StrictLabels holds every series of a metric to the same label names,
+	// taken from LabelSchema or learned from the first series to arrive.
+	// Series missing labels get MissingLabelValue, or are rejected when it is
+	// empty, series with unknown labels are always rejected.
+	StrictLabels      bool                `toml:"strict_labels"`
+	LabelSchema       map[string][]string `toml:"label_schema"`
+	MissingLabelValue string              `toml:"missing_label_value"`
+
+	// Metrics declares metric families up front, see MetricConfig.
+	Metrics map[string]*MetricConfig `toml:"metrics"`
+	// TTLOverrides maps metric name globs, or regular expressions wrapped in
+	// slashes, to the TTL of samples that don't carry expires.
+	TTLOverrides map[string]string `toml:"ttl_overrides"`
+	// ExpiryBasis is what expiry counts from: "message" for the message
+	// timestamp, "arrival" for the time the message reached the plugin or
+	// "latest" for whichever is later.
+	ExpiryBasis string `toml:"expiry_basis"`
+	// SweepInterval is how often expired samples are dropped.
+	SweepInterval string `toml:"sweep_interval"`
+	// StoreShards is the number of independently locked shards samples are
+	// spread over.
+	StoreShards int `toml:"store_shards"`
+	// DecoderWorkers is the number of goroutines decoding payloads.
+	DecoderWorkers int `toml:"decoder_workers"`
+	// RenderInterval, when set, renders the exposition once per interval and
+	// serves the cached result to every scrape.
+	RenderInterval string `toml:"render_interval"`
+	// StreamExposition writes stored samples straight into the exposition
+	// instead of collecting them through the client library.
+	StreamExposition bool `toml:"stream_exposition"`
+	// PipelineStatsInterval, when set, asks hekad for a report on all of its
+	// plugins every interval and exposes the numbers as heka_* metrics.
+	PipelineStatsInterval string `toml:"pipeline_stats_interval"`
+	// Rules derive metrics straight from the fields and headers of the
+	// messages they match, keyed by metric name.
+	Rules map[string]*RuleConfig `toml:"rules"`
+	// EnvelopeLabels attach attributes of the message envelope, Hostname,
+	// Logger, Type, EnvVersion, Pid or Fields[name], to every sample taken
+	// from the message, keyed by label name. Labels the sample already
+	// carries win.
+	EnvelopeLabels map[string]string `toml:"envelope_labels"`
+	// ProducerSource names the message attribute identifying the producer
+	// owning the samples of a message, Hostname, Logger or Fields[name] for
+	// instance, unless the payload names its producer itself.
+	ProducerSource string `toml:"producer_source"`
+	// RejectOutOfOrder discards samples whose message is older than the
+	// message of the sample stored for the same series.
+	RejectOutOfOrder bool `toml:"reject_out_of_order"`
+	// HAReplicaLabel, when set, deduplicates redundant producers: of the
+	// samples carrying it only those of the replica elected for their
+	// HAClusterLabel are kept, with the replica label stripped. Another
+	// replica is elected once the elected one has been silent for
+	// HAFailoverTimeout.
+	HAClusterLabel    string `toml:"ha_cluster_label"`
+	HAReplicaLabel    string `toml:"ha_replica_label"`
+	HAFailoverTimeout string `toml:"ha_failover_timeout"`
 }
 
 type PromOut struct {
-	config  *PromOutConfig
-	ch      chan *hekaSample
-	rlock   *sync.RWMutex
-	samples map[string]*hekaSample
+	lastScrape int64 // unix nanoseconds, first to keep it 64-bit aligned for atomic
+
+	config    *PromOutConfig
+	ch        chan *hekaSample
+	samples   *sampleStore
+	rlock     *sync.RWMutex // guards families
+	families  families
+	descs     *descCache
+	registry  *metricRegistry
+	groups    seriesIndex
+	producers seriesIndex
+	producer  messageSource
+	ha        *haTracker
+	rules     rules
+	envelope  messageLabels
+
+	inSuccess         prometheus.Counter
+	inFailure         prometheus.Counter
+	counterResets     *prometheus.CounterVec
+	typeConflicts     *prometheus.CounterVec
+	labelMismatches   *prometheus.CounterVec
+	invalidNames      prometheus.Counter
+	expiredArrival    prometheus.Counter
+	renderDuration    prometheus.Histogram
+	renderFailures    prometheus.Counter
+	seriesExpired     prometheus.Counter
+	groupDropped      prometheus.Counter
+	keyCollisions     prometheus.Counter
+	goodbyeDropped    prometheus.Counter
+	outOfOrderSamples *prometheus.CounterVec
+	haDropped         *prometheus.CounterVec
+	haFailovers       *prometheus.CounterVec
+	haNoCluster       prometheus.Counter
+	decodeDuration    prometheus.Histogram
+	payloadSize       prometheus.Histogram
+	scrapeDuration    prometheus.Histogram
+	lockWait          prometheus.Histogram
+	self              []prometheus.Collector
 
-	inSuccess       prometheus.Counter
-	inFailure       prometheus.Counter
-	errLogger       func(error)
-	defaultDuration time.Duration
+	seriesDesc       *prometheus.Desc
+	seriesByNameDesc *prometheus.Desc
+	errLogger        func(error)
+	defaultDuration  time.Duration
+	sweepInterval    time.Duration
+	renderInterval   time.Duration
+	statsInterval    time.Duration
+	exposition       *expositionCache
+	stop             chan struct{}
 }
 
 func (p *PromOut) ConfigStruct() interface{} {
 	return &PromOutConfig{
-		Address:    "0.0.0.0:9107",
-		DefaultTTL: "90s",
+		Address:           "0.0.0.0:9107",
+		DefaultTTL:        "90s",
+		ExpiryBasis:       "message",
+		SweepInterval:     "1s",
+		StoreShards:       32,
+		DecoderWorkers:    1,
+		HAClusterLabel:    "cluster",
+		HAFailoverTimeout: "30s",
 	}
 }
 
 func (p *PromOut) Init(config interface{}) error {
+	if err := p.setup(config.(*PromOutConfig)); err != nil {
+		return err
+	}
+
+	e := prometheus.Register(p)
+	if e != nil {
+		return e
+	}
+
+	http.HandleFunc("/health", func(w http.ResponseWriter, req *http.Request) {
+		io.WriteString(w, "pong!\n")
+
+	})
+	render := writeExposition
+	if p.config.StreamExposition {
+		render = p.writeStreamedExposition
+	}
+	var metrics http.Handler
+	switch {
+	case p.renderInterval > 0:
+		p.exposition = newExpositionCache(
+			render, p.renderInterval, p.renderDuration, p.renderFailures,
+			p.logError,
+		)
+		go p.exposition.run(p.stop)
+		metrics = p.exposition
+	case p.config.StreamExposition:
+		metrics = expositionHandler(render, p.logError)
+	default:
+		metrics = prometheus.Handler()
+	}
+	http.Handle("/metrics", p.recordScrapes(metrics))
+	go http.ListenAndServe(p.config.Address, nil)
+	return nil
+}
+
+// setup prepares the plugin's state from its config without registering it or
+// listening anywhere.
+func (p *PromOut) setup(config *PromOutConfig) error {
 	p.inSuccess = prometheus.NewCounter(
 		prometheus.CounterOpts{
 			Name: "hekagateway_msg_success",
 			Help: "properly formatted messages",
 		},
 	)
-	p.samples = make(map[string]*hekaSample)
+	p.families = make(families)
+	p.descs = newDescCache()
 
 	p.inFailure = prometheus.NewCounter(
 		prometheus.CounterOpts{
@@ -169,56 +540,286 @@ func (p *PromOut) Init(config interface{}) error {
 		},
 	)
 
-	p.config = config.(*PromOutConfig)
+	p.counterResets = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "hekagateway_counter_resets",
+			Help: "counters that arrived with a lower value than the one stored",
+		},
+		[]string{"name"},
+	)
+
+	p.typeConflicts = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "hekagateway_type_conflicts",
+			Help: "samples rejected for disagreeing with the stored type or help",
+		},
+		[]string{"name"},
+	)
+
+	p.labelMismatches = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "hekagateway_label_mismatches",
+			Help: "samples rejected by strict_labels",
+		},
+		[]string{"name"},
+	)
+
+	p.invalidNames = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "hekagateway_invalid_names",
+			Help: "samples dropped for a metric or label name Prometheus doesn't accept",
+		},
+	)
+
+	p.expiredArrival = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "hekagateway_expired_on_arrival",
+			Help: "samples that had already expired when they arrived",
+		},
+	)
+
+	p.renderDuration = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name: "hekagateway_render_seconds",
+			Help: "time spent rendering the cached exposition",
+		},
+	)
+
+	p.renderFailures = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "hekagateway_render_failures",
+			Help: "renders of the cached exposition that failed",
+		},
+	)
+
+	p.seriesExpired = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "hekagateway_series_expired",
+			Help: "series dropped after expiring",
+		},
+	)
+
+	p.keyCollisions = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "hekagateway_key_collisions",
+			Help: "samples dropped because their series key is taken by another series",
+		},
+	)
+
+	p.groupDropped = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "hekagateway_group_series_dropped",
+			Help: "series dropped for missing from their group's latest inventory",
+		},
+	)
+
+	p.goodbyeDropped = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "hekagateway_goodbye_series_dropped",
+			Help: "series dropped because their producer said goodbye",
+		},
+	)
+
+	p.outOfOrderSamples = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "hekagateway_out_of_order",
+			Help: "samples discarded for being older than the stored sample of their series",
+		},
+		[]string{"name"},
+	)
+
+	p.haDropped = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "hekagateway_ha_dropped",
+			Help: "samples dropped for coming from a replica that isn't elected",
+		},
+		[]string{"cluster"},
+	)
+	p.haFailovers = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "hekagateway_ha_failovers",
+			Help: "times another replica was elected after the elected one went silent",
+		},
+		[]string{"cluster"},
+	)
+	p.haNoCluster = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "hekagateway_ha_missing_cluster",
+			Help: "samples dropped for carrying a replica label but no cluster label",
+		},
+	)
+
+	p.decodeDuration = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "hekagateway_decode_seconds",
+			Help:    "time spent decoding a payload",
+			Buckets: prometheus.ExponentialBuckets(1e-5, 4, 10),
+		},
+	)
+
+	p.payloadSize = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "hekagateway_payload_bytes",
+			Help:    "size of the payloads received",
+			Buckets: prometheus.ExponentialBuckets(64, 4, 10),
+		},
+	)
+
+	p.scrapeDuration = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name: "hekagateway_scrape_seconds",
+			Help: "time spent handing stored series to a scrape",
+		},
+	)
+
+	p.lockWait = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "hekagateway_lock_wait_seconds",
+			Help:    "time spent waiting on a store shard lock to write to it",
+			Buckets: prometheus.ExponentialBuckets(1e-7, 4, 10),
+		},
+	)
+
+	p.seriesDesc = prometheus.NewDesc(
+		"hekagateway_series", "stored series by type",
+		[]string{"type"}, nil,
+	)
+	p.seriesByNameDesc = prometheus.NewDesc(
+		"hekagateway_series_by_name", "stored series by metric name",
+		[]string{"name"}, nil,
+	)
+
+	p.self = []prometheus.Collector{
+		p.inSuccess, p.inFailure, p.counterResets, p.typeConflicts,
+		p.labelMismatches, p.invalidNames, p.expiredArrival, p.renderDuration,
+		p.renderFailures, p.seriesExpired, p.keyCollisions, p.groupDropped,
+		p.goodbyeDropped, p.outOfOrderSamples, p.haDropped, p.haFailovers,
+		p.haNoCluster, p.decodeDuration, p.payloadSize, p.scrapeDuration,
+		p.lockWait,
+	}
+
+	p.config = config
+	p.stop = make(chan struct{})
+	p.samples = newSampleStore(p.config.StoreShards)
+	p.samples.lockWait = p.lockWait
+	p.groups = make(seriesIndex)
+	p.producers = make(seriesIndex)
+	if p.config.DecoderWorkers < 1 {
+		return fmt.Errorf("decoder_workers must be at least 1")
+	}
+	switch p.config.ExpiryBasis {
+	case "message", "arrival", "latest":
+	default:
+		return fmt.Errorf("unknown expiry_basis %q", p.config.ExpiryBasis)
+	}
 
 	var err error
-	p.defaultDuration, err = time.ParseDuration(p.config.DefaultTTL)
-	if err != nil {
+	if p.registry, err = newMetricRegistry(
+		p.config.Metrics, p.config.TTLOverrides,
+	); err != nil {
 		return err
 	}
-	p.rlock = &sync.RWMutex{}
 
-	e := prometheus.Register(p)
-	if e != nil {
-		return e
+	// Labels of declared metrics double as their label schema, statesets
+	// expand into series carrying one more named after the metric.
+	if p.config.LabelSchema == nil {
+		p.config.LabelSchema = make(map[string][]string)
+	}
+	for name, m := range p.config.Metrics {
+		if _, ok := p.config.LabelSchema[name]; !ok && len(m.Labels) > 0 {
+			schema := m.Labels
+			if strings.ToLower(m.Type) == "stateset" {
+				schema = append(append([]string(nil), m.Labels...), name)
+			}
+			p.config.LabelSchema[name] = schema
+		}
+	}
+	for name, schema := range p.config.LabelSchema {
+		sorted := append([]string(nil), schema...)
+		sort.Strings(sorted)
+		p.config.LabelSchema[name] = sorted
 	}
 
-	http.HandleFunc("/health", func(w http.ResponseWriter, req *http.Request) {
-		io.WriteString(w, "pong!\n")
-
-	})
-	http.Handle("/metrics", prometheus.Handler())
-	go http.ListenAndServe(p.config.Address, nil)
+	p.defaultDuration, err = time.ParseDuration(p.config.DefaultTTL)
+	if err != nil {
+		return err
+	}
+	p.sweepInterval, err = time.ParseDuration(p.config.SweepInterval)
+	if err != nil {
+		return err
+	}
+	if p.sweepInterval <= 0 {
+		return fmt.Errorf("sweep_interval %s is not positive", p.config.SweepInterval)
+	}
+	if p.config.RenderInterval != "" {
+		p.renderInterval, err = time.ParseDuration(p.config.RenderInterval)
+		if err != nil {
+			return err
+		}
+	}
+	if p.config.PipelineStatsInterval != "" {
+		p.statsInterval, err = time.ParseDuration(p.config.PipelineStatsInterval)
+		if err != nil {
+			return err
+		}
+	}
+	if p.rules, err = newRules(p.config.Rules, p.registry); err != nil {
+		return err
+	}
+	if p.envelope, err = newMessageLabels(p.config.EnvelopeLabels); err != nil {
+		return fmt.Errorf("envelope_labels: %v", err)
+	}
+	if p.config.HAReplicaLabel != "" {
+		timeout, err := time.ParseDuration(p.config.HAFailoverTimeout)
+		if err != nil {
+			return fmt.Errorf("ha_failover_timeout: %v", err)
+		}
+		p.ha = newHATracker(
+			p.config.HAClusterLabel, p.config.HAReplicaLabel, timeout,
+		)
+		p.registry.replicaLabel = p.config.HAReplicaLabel
+	}
+	if p.config.ProducerSource != "" {
+		if p.producer, err = newMessageSource(p.config.ProducerSource); err != nil {
+			return fmt.Errorf("producer_source: %v", err)
+		}
+	}
+	p.rlock = &sync.RWMutex{}
 	return nil
 }
 
 func (p *PromOut) Describe(ch chan<- *prometheus.Desc) {
-
-	p.rlock.RLock()
-	ch <- p.inSuccess.Desc()
-	ch <- p.inFailure.Desc()
-	defer p.rlock.RUnlock()
-
+	for _, c := range p.self {
+		c.Describe(ch)
+	}
+	ch <- p.seriesDesc
+	ch <- p.seriesByNameDesc
 }
+
 func (p *PromOut) Collect(ch chan<- prometheus.Metric) {
-	ch <- p.inSuccess
-	ch <- p.inFailure
+	for _, c := range p.self {
+		c.Collect(ch)
+	}
+	p.collectSeriesCounts(ch)
 
-	samples := make([]*hekaSample, 0, len(p.samples))
-	p.rlock.RLock()
-	for _, s := range p.samples {
-		samples = append(samples, s)
+	if p.config.StreamExposition {
+		// stored samples are written by writeSamples
+		return
 	}
-	p.rlock.RUnlock()
+
+	start := time.Now()
+	defer func() {
+		p.scrapeDuration.Observe(time.Since(start).Seconds())
+	}()
 
 	now := time.Now()
 	var (
 		m   prometheus.Metric
 		err error
 	)
-	for _, s := range samples {
+	p.samples.each(func(s *hekaSample) {
 		if now.After(s.expires) {
-			continue
+			return
 		}
 
 		if s.single != nil {
@@ -231,7 +832,13 @@ func (p *PromOut) Collect(ch chan<- prometheus.Metric) {
 				if p.errLogger != nil {
 					p.errLogger(err)
 				}
-				continue
+				return
+			}
+			if s.createdDesc != nil {
+				ch <- prometheus.MustNewConstMetric(
+					s.createdDesc, prometheus.GaugeValue,
+					float64(s.created.UnixNano())/1e9,
+				)
 			}
 		} else if s.hist != nil {
 			m, err = prometheus.NewConstHistogram(
@@ -244,7 +851,7 @@ func (p *PromOut) Collect(ch chan<- prometheus.Metric) {
 				if p.errLogger != nil {
 					p.errLogger(err)
 				}
-				continue
+				return
 			}
 
 		} else if s.summ != nil {
@@ -257,60 +864,441 @@ func (p *PromOut) Collect(ch chan<- prometheus.Metric) {
 		}
 
 		ch <- m
+	})
+}
+
+// collectSeriesCounts reports how many series are stored, by type and by
+// metric name.
+func (p *PromOut) collectSeriesCounts(ch chan<- prometheus.Metric) {
+	byKind := make(map[string]int)
+	p.rlock.RLock()
+	for name, f := range p.families {
+		byKind[f.kind] += f.series
+		ch <- prometheus.MustNewConstMetric(
+			p.seriesByNameDesc, prometheus.GaugeValue, float64(f.series), name,
+		)
+	}
+	p.rlock.RUnlock()
+
+	for kind, n := range byKind {
+		ch <- prometheus.MustNewConstMetric(
+			p.seriesDesc, prometheus.GaugeValue, float64(n), kind,
+		)
+	}
+}
+
+// ingest stores freshly decoded samples, replacing any previous sample for the
+// same series. Samples conflicting with the stored type or help of their
+// metric are logged and dropped, as are samples that have already expired.
+func (p *PromOut) ingest(hsamples []*hekaSample) {
+	now := time.Now()
+	var (
+		grouped map[uint64]bool
+		// entries of the inventory at hand that were discarded rather than
+		// stored, an inventory missing any replaces nothing since the series
+		// they were meant to update would go with the rest
+		discarded int
+	)
+	for _, h := range hsamples {
+		switch {
+		case h.groupEnd:
+			// a stale or incomplete inventory replaces nothing
+			if discarded == 0 && !now.After(h.expires) {
+				p.replaceGroup(h.group, grouped)
+			}
+			grouped, discarded = nil, 0
+			continue
+		case h.goodbye:
+			p.dropProducer(h.producer)
+			continue
+		case !h.extend.IsZero():
+			p.extendProducer(h.producer, h.extend)
+			continue
+		}
+
+		if h.group != "" {
+			discarded++
+		}
+		if now.After(h.expires) {
+			p.expiredArrival.Inc()
+			continue
+		}
+		if !p.dedupe(h, now) {
+			continue
+		}
+		if !p.admit(h) {
+			continue
+		}
+
+		name, help, _ := sampleFamily(h)
+		labels := sampleLabels(h)
+		h.key = seriesKey(name, labels)
+		old, err := p.samples.get(h)
+		if err != nil {
+			p.keyCollisions.Inc()
+			p.logError(fmt.Errorf("%s %v: %v", name, labels, err))
+			continue
+		}
+		if p.outOfOrder(old, h) {
+			p.outOfOrderSamples.WithLabelValues(name).Inc()
+			continue
+		}
+		h.desc = p.descs.get(h.key, name, help, labels)
+		if old != nil {
+			// an expired sample the sweeper hasn't got to yet is gone,
+			// rule-derived counters start over instead of adding onto it
+			if h.accumulate && !now.After(old.expires) {
+				accumulate(old, h)
+			}
+			p.checkCounterReset(old, h)
+		}
+		// only this goroutine stores samples, get has ruled out a collision
+		old, _ = p.samples.put(h)
+		if old == nil {
+			p.descs.retain(h.key)
+			p.rlock.Lock()
+			p.families.add(h)
+			p.rlock.Unlock()
+			old = &hekaSample{}
+		}
+		p.groups.move(old.group, h.group, h.key)
+		p.producers.move(old.producer, h.producer, h.key)
+		if h.group != "" {
+			if grouped == nil {
+				grouped = make(map[uint64]bool)
+			}
+			grouped[h.key] = true
+			discarded--
+		}
+		p.inSuccess.Inc()
+	}
+}
+
+// dedupe drops h unless it comes from the elected replica of its cluster,
+// stripping the replica label off the samples it keeps. Samples without a
+// replica label are kept as they are, samples with a replica but without a
+// cluster label are dropped rather than share an election with every other
+// cluster missing it.
+func (p *PromOut) dedupe(h *hekaSample, now time.Time) bool {
+	if p.ha == nil {
+		return true
+	}
+	labels := sampleLabels(h)
+	replica, ok := labels[p.ha.replicaLabel]
+	if !ok {
+		return true
+	}
+	cluster, ok := labels[p.ha.clusterLabel]
+	if !ok || cluster == "" {
+		name, _, _ := sampleFamily(h)
+		p.haNoCluster.Inc()
+		p.logError(fmt.Errorf(
+			"metric %q: replica %q without a %s label", name, replica, p.ha.clusterLabel,
+		))
+		return false
+	}
+
+	accepted, failover := p.ha.elect(cluster, replica, now)
+	if failover {
+		p.haFailovers.WithLabelValues(cluster).Inc()
+	}
+	if !accepted {
+		p.haDropped.WithLabelValues(cluster).Inc()
+		return false
+	}
+	setSampleLabels(h, p.ha.strip(labels))
+	return true
+}
+
+// outOfOrder reports whether h is older than old, the sample stored for its
+// series, and should be discarded. Only with reject_out_of_order on, and never
+// for samples derived by rules which add to the stored one. Delayed retries
+// are expected to cause these, so they are counted but not logged.
+func (p *PromOut) outOfOrder(old, h *hekaSample) bool {
+	return p.config.RejectOutOfOrder && old != nil && !h.accumulate &&
+		h.timestamp.Before(old.timestamp)
+}
+
+// replaceGroup drops the series of group that are not in keep, the keys of
+// the series of the group's latest inventory.
+func (p *PromOut) replaceGroup(group string, keep map[uint64]bool) {
+	for key := range p.groups[group] {
+		if !keep[key] && p.drop(key) {
+			p.groupDropped.Inc()
+		}
+	}
+}
+
+// dropProducer drops every series of a producer that said goodbye.
+func (p *PromOut) dropProducer(producer string) {
+	for key := range p.producers[producer] {
+		if p.drop(key) {
+			p.goodbyeDropped.Inc()
+		}
+	}
+}
+
+// extendProducer keeps every series of a producer that sent a heartbeat
+// until at least expires.
+func (p *PromOut) extendProducer(producer string, expires time.Time) {
+	for key := range p.producers[producer] {
+		p.samples.extend(key, expires)
+	}
+}
+
+// drop removes the series stored under key ahead of its expiry.
+func (p *PromOut) drop(key uint64) bool {
+	s := p.samples.remove(key)
+	if s == nil {
+		return false
+	}
+	p.unindex(s)
+	return true
+}
+
+// unindex forgets a sample that has left the store.
+func (p *PromOut) unindex(s *hekaSample) {
+	p.rlock.Lock()
+	p.families.remove(s)
+	p.rlock.Unlock()
+	p.descs.release(s.key)
+	p.groups.move(s.group, "", s.key)
+	p.producers.move(s.producer, "", s.key)
+}
+
+// admit checks h against the family it belongs to, logging and counting it
+// when it doesn't fit.
+func (p *PromOut) admit(h *hekaSample) bool {
+	if err := checkNames(h); err != nil {
+		p.invalidNames.Inc()
+		p.logError(err)
+		return false
+	}
+
+	p.rlock.RLock()
+	defer p.rlock.RUnlock()
+
+	if err := p.families.check(h); err != nil {
+		name, _, _ := sampleFamily(h)
+		p.typeConflicts.WithLabelValues(name).Inc()
+		p.logError(err)
+		return false
+	}
+	if err := p.checkLabels(h); err != nil {
+		name, _, _ := sampleFamily(h)
+		p.labelMismatches.WithLabelValues(name).Inc()
+		p.logError(err)
+		return false
+	}
+	return true
+}
+
+// checkLabels holds h to the label schema of its metric when strict_labels is
+// on, filling in missing labels when configured to.
+func (p *PromOut) checkLabels(h *hekaSample) error {
+	if !p.config.StrictLabels {
+		return nil
+	}
+	name, _, _ := sampleFamily(h)
+	schema, ok := p.config.LabelSchema[name]
+	if !ok {
+		f, ok := p.families[name]
+		if !ok {
+			return nil
+		}
+		schema = f.labels
+	}
+
+	labels := sampleLabels(h)
+	conformed, err := conformLabels(labels, schema, p.config.MissingLabelValue)
+	if err != nil {
+		return fmt.Errorf("metric %q: %v", name, err)
 	}
+	if len(conformed) != len(labels) {
+		setSampleLabels(h, conformed)
+	}
+	return nil
+}
+
+// expiryBase returns the time a message's samples expire from.
+func (p *PromOut) expiryBase(msgTime, arrival time.Time) time.Time {
+	switch p.config.ExpiryBasis {
+	case "arrival":
+		return arrival
+	case "latest":
+		if arrival.After(msgTime) {
+			return arrival
+		}
+	}
+	return msgTime
 }
 
+func (p *PromOut) logError(err error) {
+	if p.errLogger != nil {
+		p.errLogger(err)
+	}
+}
+
+// checkCounterReset compares a counter against the sample it replaces. A
+// counter that went backwards was either reset by a restarting producer or is
+// being fed garbage, either way it is counted so misbehaving filters stand out.
+func (p *PromOut) checkCounterReset(old, h *hekaSample) {
+	if h.single == nil || old.single == nil ||
+		h.single.valueType != prometheus.CounterValue ||
+		old.single.valueType != prometheus.CounterValue {
+		return
+	}
+
+	if h.single.Value >= old.single.Value {
+		h.created, h.createdDesc = old.created, old.createdDesc
+		return
+	}
+
+	p.counterResets.WithLabelValues(h.single.Name).Inc()
+	if p.config.EmitCreated {
+		h.created = h.timestamp
+		h.createdDesc = prometheus.NewDesc(
+			h.single.Name+"_created", h.single.Help, []string{},
+			h.single.Labels,
+		)
+	}
+}
+
+// Run decodes packs on decoder_workers goroutines and applies the decoded
+// samples to the store on its own, so ingestion and sweeping never race each
+// other.
 func (p *PromOut) Run(or pipeline.OutputRunner, ph pipeline.PluginHelper) (err error) {
 	var (
 		running  bool = true
-		pack     *pipeline.PipelinePack
 		hsamples []*hekaSample
+		wg       sync.WaitGroup
 	)
 
-	ticker := time.NewTicker(time.Minute).C
+	p.errLogger = or.LogError
+
+	decoded := make(chan []*hekaSample, p.config.DecoderWorkers)
+	for i := 0; i < p.config.DecoderWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.decode(or.InChan(), decoded)
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(decoded)
+	}()
+
+	if p.statsInterval > 0 {
+		go requestReports(ph.PipelineConfig(), p.statsInterval, p.stop)
+	}
+
+	ticker := time.NewTicker(p.sweepInterval).C
 	for running {
 		select {
-		case pack, running = <-or.InChan():
-			if !running {
-				continue
+		case hsamples, running = <-decoded:
+			if running {
+				p.ingest(hsamples)
 			}
 
-			payload := []byte(pack.Message.GetPayload())
-			msgTime := time.Unix(0, pack.Message.GetTimestamp())
-			hsamples, err = newHekaSampleScalar(
-				payload, p.defaultDuration, msgTime,
-			)
-			if err == nil {
-				p.rlock.Lock()
-				for _, h := range hsamples {
-					p.samples[h.desc.String()] = h
-					p.inSuccess.Inc()
-				}
-				p.rlock.Unlock()
+		case <-ticker:
+			p.sweep(time.Now())
+		}
+	}
+	close(p.stop)
+	return nil
+}
 
-			} else {
-				or.LogError(fmt.Errorf("%v message\n<msg>\n%s\n</msg>", err, payload))
+// decode turns packs into samples until the input channel closes, recycling
+// every pack as soon as it has been read.
+func (p *PromOut) decode(in chan *pipeline.PipelinePack, decoded chan<- []*hekaSample) {
+	for pack := range in {
+		hsamples, err := p.decodePack(pack)
+		if err != nil {
+			p.logError(err)
+			p.inFailure.Inc()
+		}
+		if len(hsamples) > 0 {
+			decoded <- hsamples
+		}
+	}
+}
 
-				p.inFailure.Inc()
-			}
+// decodePack turns one pack into samples and recycles it. Messages matched
+// by a rule are derived from, the payload of any other message is decoded
+// once the pack is back in the pool.
+func (p *PromOut) decodePack(pack *pipeline.PipelinePack) ([]*hekaSample, error) {
+	start := time.Now()
+	defer func() {
+		p.decodeDuration.Observe(time.Since(start).Seconds())
+	}()
 
-			pack.Recycle()
+	var envelope map[string]string
+	if len(p.envelope) > 0 {
+		envelope = make(map[string]string, len(p.envelope))
+		p.envelope.read(pack.Message, envelope)
+	}
+	var producer string
+	if p.producer != nil {
+		producer, _ = p.producer(pack.Message)
+	}
 
-		case <-ticker:
-			// clearn up expired samples
-		}
+	msgTime := time.Unix(0, pack.Message.GetTimestamp())
+	hsamples, matched, err := p.rules.derive(
+		pack.Message, envelope, msgTime, p.expiryBase(msgTime, start),
+	)
+	if matched {
+		pack.Recycle()
+		setProducer(hsamples, producer)
+		return hsamples, err
+	}
+	payload := []byte(pack.Message.GetPayload())
+	report := pack.Message.GetType() == allReportType
+	pack.Recycle()
 
-		now := time.Now()
-		p.rlock.Lock()
-		for k, s := range p.samples {
-			if now.After(s.expires) {
-				delete(p.samples, k)
-			}
+	p.payloadSize.Observe(float64(len(payload)))
+	if report && p.statsInterval > 0 {
+		// outlive a missed report or two, but not a stopped hekad
+		hsamples, err = pipelineSamples(
+			payload, 3*p.statsInterval, msgTime, start,
+		)
+		addLabels(hsamples, envelope)
+	} else {
+		hsamples, err = newHekaSamples(
+			payload, envelope, p.registry, p.defaultDuration,
+			msgTime, p.expiryBase(msgTime, start),
+		)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%v message\n<msg>\n%s\n</msg>", err, payload)
+	}
+	setProducer(hsamples, producer)
+	return hsamples, nil
+}
+
+// setProducer hands producer to the samples not naming their own.
+func setProducer(hsamples []*hekaSample, producer string) {
+	if producer == "" {
+		return
+	}
+	for _, h := range hsamples {
+		if h.producer == "" {
+			h.producer = producer
 		}
-		p.rlock.Unlock()
+	}
+}
 
+// sweep drops the samples that have expired by now and forgets the HA
+// elections of clusters long gone.
+func (p *PromOut) sweep(now time.Time) {
+	expired := p.samples.sweep(now)
+	p.seriesExpired.Add(float64(len(expired)))
+	for _, s := range expired {
+		p.unindex(s)
+	}
+	if p.ha != nil {
+		p.ha.prune(now)
 	}
-	return nil
 }
 
 func init() {
@@ -1,10 +1,19 @@
 package prometheus
 
 import (
+	"github.com/mozilla-services/heka/message"
+	"github.com/mozilla-services/heka/pipeline"
 	"github.com/pquerna/ffjson/ffjson"
+	"github.com/prometheus/client_golang/prometheus"
 
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
-	//"time"
+	"time"
 )
 
 func TestBasicJson(t *testing.T) {
@@ -140,6 +149,732 @@ func TestBasicJson(t *testing.T) {
 
 }
 
+func TestInfoAndStateSet(t *testing.T) {
+	payload := `
+{
+  "single": [
+    {
+      "name": "build",
+      "valuetype": "info",
+      "help": "build information",
+      "labels": {
+        "version": "1.2.3"
+      }
+    },
+    {
+      "name": "door",
+      "valuetype": "stateset",
+      "help": "state of the door",
+      "labels": {
+        "room": "kitchen"
+      },
+      "states": {
+        "open": true,
+        "closed": false
+      }
+    }
+  ]
+}
+`
+	hsamples, err := newHekaSampleScalar([]byte(payload), nil, time.Minute, time.Now(), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hsamples) != 3 {
+		t.Fatalf("expected 3 samples, got %d", len(hsamples))
+	}
+
+	info := hsamples[0].single
+	if info.Name != "build_info" || info.Value != 1 {
+		t.Errorf("info metric not expanded: %s=%v", info.Name, info.Value)
+	}
+
+	for _, h := range hsamples[1:] {
+		s := h.single
+		if s.Labels["room"] != "kitchen" {
+			t.Errorf("stateset lost its labels: %v", s.Labels)
+		}
+		switch s.Labels["door"] {
+		case "open":
+			if s.Value != 1 {
+				t.Errorf("open state should be 1, got %v", s.Value)
+			}
+		case "closed":
+			if s.Value != 0 {
+				t.Errorf("closed state should be 0, got %v", s.Value)
+			}
+		default:
+			t.Errorf("unexpected state label: %v", s.Labels)
+		}
+	}
+}
+
+func TestUnknownValueType(t *testing.T) {
+	for _, vt := range []string{"", "gauges", "histogram"} {
+		payload := `{"single": [{"name": "foo", "value": 1, "valuetype": "` + vt + `"}]}`
+		if _, err := newHekaSampleScalar([]byte(payload), nil, time.Minute, time.Now(), time.Now()); err == nil {
+			t.Errorf("valuetype %q should have been rejected", vt)
+		}
+	}
+
+	payload := `{"single": [{"name": "foo", "valuetype": "stateset"}]}`
+	if _, err := newHekaSampleScalar([]byte(payload), nil, time.Minute, time.Now(), time.Now()); err == nil {
+		t.Errorf("stateset without states should have been rejected")
+	}
+}
+
+func TestDocumentDefaults(t *testing.T) {
+	payload := `{
+	  "labels": {"dc": "ams", "role": "web"},
+	  "help": "shared help",
+	  "expires": 30,
+	  "valuetype": "gauge",
+	  "single": [
+	    {"name": "a", "value": 1},
+	    {"name": "b", "value": 2, "valuetype": "counter", "help": "own help",
+	     "expires": 60, "labels": {"role": "db"}}
+	  ],
+	  "histogram": [{"name": "c", "count": 1, "sum": 1}],
+	  "summary": [{"name": "d", "count": 1, "sum": 1, "help": "own help"}]
+	}`
+	now := time.Now()
+	hsamples, err := newHekaSampleScalar([]byte(payload), nil, time.Minute, now, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hsamples) != 4 {
+		t.Fatalf("expected 4 samples, got %d", len(hsamples))
+	}
+
+	a, b := hsamples[0].single, hsamples[1].single
+	if a.valueType != prometheus.GaugeValue || a.Help != "shared help" ||
+		a.Labels["dc"] != "ams" || a.Labels["role"] != "web" {
+		t.Errorf("defaults not applied: %+v", a)
+	}
+	if !hsamples[0].expires.Equal(now.Add(30 * time.Second)) {
+		t.Errorf("expected the document's expires, got %v", hsamples[0].expires.Sub(now))
+	}
+	if b.valueType != prometheus.CounterValue || b.Help != "own help" ||
+		b.Labels["dc"] != "ams" || b.Labels["role"] != "db" {
+		t.Errorf("entry didn't override the defaults: %+v", b)
+	}
+	if !hsamples[1].expires.Equal(now.Add(time.Minute)) {
+		t.Errorf("expected the entry's expires, got %v", hsamples[1].expires.Sub(now))
+	}
+	for _, h := range hsamples[2:] {
+		name, help, _ := sampleFamily(h)
+		if sampleLabels(h)["dc"] != "ams" {
+			t.Errorf("%s: labels not applied: %v", name, sampleLabels(h))
+		}
+		if name == "c" && help != "shared help" || name == "d" && help != "own help" {
+			t.Errorf("%s: unexpected help %q", name, help)
+		}
+	}
+}
+
+func TestVector(t *testing.T) {
+	payload := `{
+	  "labels": {"dc": "ams"},
+	  "vector": [{
+	    "name": "queue_depth", "valuetype": "gauge", "help": "messages queued",
+	    "labelnames": ["host", "queue"],
+	    "labelvalues": [["web1", "mail"], ["web1", "jobs"]],
+	    "values": [3, 7]
+	  }]
+	}`
+	hsamples, err := newHekaSampleScalar([]byte(payload), nil, time.Minute, time.Now(), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hsamples) != 2 {
+		t.Fatalf("expected 2 samples, got %d", len(hsamples))
+	}
+	for i, want := range []struct {
+		queue string
+		value float64
+	}{{"mail", 3}, {"jobs", 7}} {
+		c := hsamples[i].single
+		if c.Name != "queue_depth" || c.Help != "messages queued" ||
+			c.valueType != prometheus.GaugeValue || c.Value != want.value ||
+			c.Labels["queue"] != want.queue || c.Labels["host"] != "web1" ||
+			c.Labels["dc"] != "ams" {
+			t.Errorf("series %d not expanded: %+v", i, c)
+		}
+	}
+
+	for _, bad := range []string{
+		`{"vector": [{"name": "a", "valuetype": "gauge", "labelnames": ["x"],
+		  "labelvalues": [["1"]], "values": [1, 2]}]}`,
+		`{"vector": [{"name": "a", "valuetype": "gauge", "labelnames": ["x"],
+		  "labelvalues": [["1", "2"]], "values": [1]}]}`,
+	} {
+		if _, err := newHekaSampleScalar([]byte(bad), nil, time.Minute, time.Now(), time.Now()); err == nil {
+			t.Errorf("expected an error for %s", bad)
+		}
+	}
+}
+
+func TestOutOfOrder(t *testing.T) {
+	config := new(PromOut).ConfigStruct().(*PromOutConfig)
+	config.RejectOutOfOrder = true
+	p := newTestPromOut(t, config)
+
+	now := time.Now()
+	for i, sent := range []time.Duration{0, 2 * time.Second, time.Second, 2 * time.Second} {
+		ingestPayload(t, p, now.Add(sent), fmt.Sprintf(
+			`{"single": [{"name": "foo", "valuetype": "gauge", "value": %d}]}`, i,
+		))
+	}
+	p.samples.each(func(s *hekaSample) {
+		if s.single.Value != 3 {
+			t.Errorf("expected the latest value to stick, got %v", s.single.Value)
+		}
+	})
+	if n := counterValue(p.outOfOrderSamples.WithLabelValues("foo")); n != 1 {
+		t.Errorf("expected 1 out of order sample, got %v", n)
+	}
+}
+
+func newTestPromOut(t testing.TB, config *PromOutConfig) *PromOut {
+	p := new(PromOut)
+	if config == nil {
+		config = p.ConfigStruct().(*PromOutConfig)
+	}
+	if err := p.setup(config); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// ingestPayload decodes payload as if sent at timestamp and ingests it.
+func ingestPayload(t testing.TB, p *PromOut, timestamp time.Time, payload string) {
+	hsamples, err := newHekaSampleScalar([]byte(payload), p.registry, time.Minute, timestamp, timestamp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.ingest(hsamples)
+}
+
+func counterPayload(value string) []byte {
+	return []byte(`{"single": [{"name": "requests", "valuetype": "counter", "value": ` +
+		value + `, "labels": {"host": "web1"}}]}`)
+}
+
+func TestCounterReset(t *testing.T) {
+	config := new(PromOut).ConfigStruct().(*PromOutConfig)
+	config.EmitCreated = true
+	p := newTestPromOut(t, config)
+
+	now := time.Now()
+	for i, v := range []string{"10", "20", "5"} {
+		hsamples, err := newHekaSampleScalar(
+			counterPayload(v), nil, time.Minute,
+			now.Add(time.Duration(i)*time.Second), now,
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.ingest(hsamples)
+	}
+
+	if n := counterValue(p.counterResets.WithLabelValues("requests")); n != 1 {
+		t.Errorf("expected 1 reset, got %v", n)
+	}
+	p.samples.each(func(s *hekaSample) {
+		if s.createdDesc == nil || !s.created.Equal(now.Add(2*time.Second)) {
+			t.Errorf("created timestamp not set on reset: %v", s.created)
+		}
+	})
+}
+
+func TestTypeConflict(t *testing.T) {
+	p := newTestPromOut(t, nil)
+	var logged []error
+	p.errLogger = func(err error) { logged = append(logged, err) }
+
+	for _, payload := range []string{
+		`{"single": [{"name": "foo", "valuetype": "gauge", "help": "foo", "value": 1}]}`,
+		`{"single": [{"name": "foo", "valuetype": "counter", "help": "foo", "value": 1,
+		  "labels": {"a": "b"}}]}`,
+		`{"single": [{"name": "foo", "valuetype": "gauge", "help": "bar", "value": 1,
+		  "labels": {"a": "c"}}]}`,
+		`{"histogram": [{"name": "foo", "help": "foo", "count": 1, "sum": 1}]}`,
+		`{"single": [{"name": "foo", "valuetype": "gauge", "help": "foo", "value": 2,
+		  "labels": {"a": "d"}}]}`,
+	} {
+		hsamples, err := newHekaSampleScalar([]byte(payload), nil, time.Minute, time.Now(), time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.ingest(hsamples)
+	}
+
+	if p.samples.len() != 2 {
+		t.Errorf("expected 2 stored series, got %d", p.samples.len())
+	}
+	if len(logged) != 3 {
+		t.Errorf("expected 3 logged conflicts, got %v", logged)
+	}
+	if n := counterValue(p.typeConflicts.WithLabelValues("foo")); n != 3 {
+		t.Errorf("expected 3 conflicts, got %v", n)
+	}
+	if f := p.families["foo"]; f == nil || f.series != 2 || f.kind != "gauge" {
+		t.Errorf("family not tracked: %+v", f)
+	}
+}
+
+func TestSuffixConflict(t *testing.T) {
+	p := newTestPromOut(t, nil)
+	p.errLogger = func(error) {}
+	for _, payload := range []string{
+		`{"single": [{"name": "foo_count", "valuetype": "gauge", "value": 1}]}`,
+		`{"histogram": [{"name": "foo", "count": 1, "sum": 1}]}`,
+		`{"summary": [{"name": "bar", "count": 1, "sum": 1}]}`,
+		`{"single": [{"name": "bar_sum", "valuetype": "gauge", "value": 1}]}`,
+		`{"single": [{"name": "bar_bucket", "valuetype": "gauge", "value": 1}]}`,
+	} {
+		ingestPayload(t, p, time.Now(), payload)
+	}
+
+	if n := p.samples.len(); n != 3 {
+		t.Errorf("expected foo_count, bar and bar_bucket stored, got %d series", n)
+	}
+	if n := counterValue(p.typeConflicts.WithLabelValues("foo")); n != 1 {
+		t.Errorf("expected the histogram to conflict, got %v", n)
+	}
+	if n := counterValue(p.typeConflicts.WithLabelValues("bar_sum")); n != 1 {
+		t.Errorf("expected the gauge to conflict, got %v", n)
+	}
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(p)
+	if _, err := registry.Gather(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestInvalidNames(t *testing.T) {
+	p := newTestPromOut(t, nil)
+	var logged []error
+	p.errLogger = func(err error) { logged = append(logged, err) }
+
+	hsamples, err := newHekaSampleScalar([]byte(`{"single": [
+		{"name": "foo", "valuetype": "gauge", "value": 1},
+		{"name": "foo-bar", "valuetype": "gauge", "value": 1},
+		{"name": "foo", "valuetype": "gauge", "value": 1, "labels": {"a b": "c"}},
+		{"name": "foo", "valuetype": "gauge", "value": 1, "labels": {"__a": "c"}}
+	]}`), nil, time.Minute, time.Now(), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.ingest(hsamples)
+
+	if p.samples.len() != 1 {
+		t.Errorf("expected 1 stored series, got %d", p.samples.len())
+	}
+	if n := counterValue(p.invalidNames); n != 3 || len(logged) != 3 {
+		t.Errorf("expected 3 invalid names, got %v, %v", n, logged)
+	}
+	var b bytes.Buffer
+	if err := p.writeSamples(&b); err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != "# TYPE foo gauge\nfoo 1\n" {
+		t.Errorf("unexpected exposition\n%s", b.String())
+	}
+}
+
+func TestStrictLabels(t *testing.T) {
+	ingest := func(p *PromOut, payload string) {
+		hsamples, err := newHekaSampleScalar([]byte(payload), nil, time.Minute, time.Now(), time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.ingest(hsamples)
+	}
+
+	config := new(PromOut).ConfigStruct().(*PromOutConfig)
+	config.StrictLabels = true
+	p := newTestPromOut(t, config)
+	ingest(p, `{"single": [{"name": "foo", "valuetype": "gauge", "value": 1, "labels": {"a": "1", "b": "1"}}]}`)
+	ingest(p, `{"single": [{"name": "foo", "valuetype": "gauge", "value": 1, "labels": {"a": "2"}}]}`)
+	ingest(p, `{"single": [{"name": "foo", "valuetype": "gauge", "value": 1, "labels": {"a": "3", "c": "3"}}]}`)
+	ingest(p, `{"single": [{"name": "foo", "valuetype": "gauge", "value": 1, "labels": {"b": "4", "a": "4"}}]}`)
+	if p.samples.len() != 2 {
+		t.Errorf("expected 2 series to fit the learned schema, got %d", p.samples.len())
+	}
+	if n := counterValue(p.labelMismatches.WithLabelValues("foo")); n != 2 {
+		t.Errorf("expected 2 mismatches, got %v", n)
+	}
+
+	config = new(PromOut).ConfigStruct().(*PromOutConfig)
+	config.StrictLabels = true
+	config.LabelSchema = map[string][]string{"foo": {"b", "a"}}
+	config.MissingLabelValue = "none"
+	p = newTestPromOut(t, config)
+	ingest(p, `{"single": [{"name": "foo", "valuetype": "gauge", "value": 1, "labels": {"a": "1"}}]}`)
+	ingest(p, `{"single": [{"name": "foo", "valuetype": "gauge", "value": 1, "labels": {"c": "1"}}]}`)
+	if p.samples.len() != 1 {
+		t.Fatalf("expected 1 series, got %d", p.samples.len())
+	}
+	p.samples.each(func(s *hekaSample) {
+		if s.single.Labels["b"] != "none" || s.single.Labels["a"] != "1" {
+			t.Errorf("missing label not filled: %v", s.single.Labels)
+		}
+	})
+
+	// the label a stateset adds is part of its declared schema
+	config = new(PromOut).ConfigStruct().(*PromOutConfig)
+	config.StrictLabels = true
+	config.Metrics = map[string]*MetricConfig{
+		"door": {Type: "stateset", Labels: []string{"room"}},
+	}
+	p = newTestPromOut(t, config)
+	hsamples, err := newHekaSampleScalar([]byte(`{"single": [{"name": "door",
+	  "states": {"open": true, "closed": false}, "labels": {"room": "hall"}}]}`),
+		p.registry, time.Minute, time.Now(), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.ingest(hsamples)
+	if p.samples.len() != 2 {
+		t.Errorf("expected both states stored, got %d series", p.samples.len())
+	}
+}
+
+func TestExpiryBasis(t *testing.T) {
+	payload := []byte(`{"single": [{"name": "foo", "valuetype": "gauge", "value": 1}]}`)
+	msgTime := time.Now().Add(-10 * time.Minute)
+
+	for basis, stored := range map[string]int{
+		"message": 0,
+		"arrival": 1,
+		"latest":  1,
+	} {
+		config := new(PromOut).ConfigStruct().(*PromOutConfig)
+		config.ExpiryBasis = basis
+		p := newTestPromOut(t, config)
+
+		hsamples, err := newHekaSampleScalar(
+			payload, nil, time.Minute, msgTime, p.expiryBase(msgTime, time.Now()),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.ingest(hsamples)
+
+		if p.samples.len() != stored {
+			t.Errorf("%s: expected %d stored samples, got %d", basis, stored, p.samples.len())
+		}
+		if n := counterValue(p.expiredArrival); n != float64(1-stored) {
+			t.Errorf("%s: expected %d expired on arrival, got %v", basis, 1-stored, n)
+		}
+	}
+}
+
+func TestSweep(t *testing.T) {
+	p := newTestPromOut(t, nil)
+	now := time.Now()
+	for _, payload := range []string{
+		`{"single": [{"name": "a", "valuetype": "gauge", "value": 1, "expires": 30}]}`,
+		`{"single": [{"name": "b", "valuetype": "gauge", "value": 1, "expires": 10}]}`,
+		`{"single": [{"name": "c", "valuetype": "gauge", "value": 1, "expires": 20}]}`,
+		// replaces a, now expiring before c
+		`{"single": [{"name": "a", "valuetype": "gauge", "value": 2, "expires": 15}]}`,
+	} {
+		hsamples, err := newHekaSampleScalar([]byte(payload), nil, time.Minute, now, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.ingest(hsamples)
+	}
+
+	for _, step := range []struct {
+		after time.Duration
+		left  int
+	}{
+		{5 * time.Second, 3},
+		{12 * time.Second, 2},
+		{17 * time.Second, 1},
+		{25 * time.Second, 0},
+	} {
+		p.sweep(now.Add(step.after))
+		queued := 0
+		for _, sh := range p.samples.shards {
+			queued += len(sh.expiry)
+		}
+		if p.samples.len() != step.left || queued != step.left {
+			t.Errorf("after %v: expected %d samples, got %d (%d in heap)",
+				step.after, step.left, p.samples.len(), queued)
+		}
+	}
+	if len(p.families) != 0 {
+		t.Errorf("families outlived their series: %v", p.families)
+	}
+
+	for _, interval := range []string{"0s", "-1m"} {
+		config := new(PromOut).ConfigStruct().(*PromOutConfig)
+		config.SweepInterval = interval
+		if err := new(PromOut).setup(config); err == nil {
+			t.Errorf("expected an error for sweep_interval %s", interval)
+		}
+	}
+}
+
+func TestDecodeWorkers(t *testing.T) {
+	p := newTestPromOut(t, nil)
+	p.errLogger = func(error) {}
+
+	const packs = 100
+	in := make(chan *pipeline.PipelinePack, packs)
+	recycled := make(chan *pipeline.PipelinePack, packs)
+	for i := 0; i < packs; i++ {
+		pack := pipeline.NewPipelinePack(recycled)
+		pack.Message.SetTimestamp(time.Now().UnixNano())
+		if i%10 == 0 {
+			pack.Message.SetPayload("not json")
+		} else {
+			pack.Message.SetPayload(fmt.Sprintf(
+				`{"single": [{"name": "foo", "valuetype": "gauge", "value": %d,
+				  "labels": {"n": "%d"}}]}`, i, i,
+			))
+		}
+		in <- pack
+	}
+	close(in)
+
+	decoded := make(chan []*hekaSample, packs)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.decode(in, decoded)
+		}()
+	}
+	wg.Wait()
+	close(decoded)
+
+	for hsamples := range decoded {
+		p.ingest(hsamples)
+	}
+	if n := p.samples.len(); n != 90 {
+		t.Errorf("expected 90 series, got %d", n)
+	}
+	if n := counterValue(p.inFailure); n != 10 {
+		t.Errorf("expected 10 failures, got %v", n)
+	}
+	if n := len(recycled); n != packs {
+		t.Errorf("expected %d recycled packs, got %d", packs, n)
+	}
+}
+
+func TestSeriesCounts(t *testing.T) {
+	p := newTestPromOut(t, nil)
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(p)
+
+	now := time.Now()
+	hsamples, err := newHekaSampleScalar([]byte(`
+{
+  "single": [
+    {"name": "a", "valuetype": "gauge", "value": 1, "labels": {"n": "1"}},
+    {"name": "a", "valuetype": "gauge", "value": 1, "labels": {"n": "2"}, "expires": 5},
+    {"name": "b", "valuetype": "counter", "value": 1}
+  ],
+  "histogram": [{"name": "c", "count": 1, "sum": 1}]
+}`), nil, time.Minute, now, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.ingest(hsamples)
+	p.sweep(now.Add(10 * time.Second))
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]float64)
+	for _, f := range families {
+		for _, m := range f.Metric {
+			switch f.GetName() {
+			case "hekagateway_series", "hekagateway_series_by_name":
+				got[f.GetName()+"/"+m.Label[0].GetValue()] = m.GetGauge().GetValue()
+			case "hekagateway_series_expired":
+				got[f.GetName()] = m.GetCounter().GetValue()
+			}
+		}
+	}
+	for k, v := range map[string]float64{
+		"hekagateway_series/gauge":     1,
+		"hekagateway_series/counter":   1,
+		"hekagateway_series/histogram": 1,
+		"hekagateway_series_by_name/a": 1,
+		"hekagateway_series_by_name/b": 1,
+		"hekagateway_series_by_name/c": 1,
+		"hekagateway_series_expired":   1,
+	} {
+		if got[k] != v {
+			t.Errorf("%s: expected %v, got %v", k, v, got[k])
+		}
+	}
+}
+
+func TestReportMsg(t *testing.T) {
+	p := newTestPromOut(t, nil)
+	now := time.Now()
+	hsamples, err := newHekaSampleScalar([]byte(`{"single": [
+	  {"name": "a", "valuetype": "gauge", "value": 1},
+	  {"name": "b", "valuetype": "gauge", "value": 1}
+	]}`), nil, time.Minute, now, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.ingest(hsamples)
+	p.inFailure.Inc()
+
+	msg := &message.Message{}
+	if err := p.ReportMsg(msg); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]interface{}{
+		"SeriesCount":  int64(2),
+		"SuccessCount": int64(2),
+		"FailureCount": int64(1),
+		"ExpiredCount": int64(0),
+		"LastScrape":   "never",
+	} {
+		if got, _ := msg.GetFieldValue(name); got != want {
+			t.Errorf("%s: expected %v, got %v", name, want, got)
+		}
+	}
+
+	// renders of a cached exposition aren't scrapes
+	p.Collect(make(chan prometheus.Metric, 100))
+	msg = &message.Message{}
+	p.ReportMsg(msg)
+	if got, _ := msg.GetFieldValue("LastScrape"); got != "never" {
+		t.Errorf("render recorded as a scrape at %v", got)
+	}
+
+	p.recordScrapes(http.NotFoundHandler()).ServeHTTP(
+		httptest.NewRecorder(), httptest.NewRequest("GET", "/metrics", nil),
+	)
+	msg = &message.Message{}
+	p.ReportMsg(msg)
+	if got, _ := msg.GetFieldValue("LastScrape"); got == "never" {
+		t.Errorf("scrape not recorded")
+	}
+}
+
+func TestPipelineSamples(t *testing.T) {
+	p := newTestPromOut(t, nil)
+	now := time.Now()
+	hsamples, err := pipelineSamples([]byte(`{
+	  "globals": [{"Name": "inputRecycleChan",
+	    "InChanCapacity": {"value": 100, "representation": "count"},
+	    "InChanLength": {"value": 98, "representation": "count"}}],
+	  "filters": [{"Name": "counter",
+	    "ProcessMessageCount": {"value": 42, "representation": "count"},
+	    "MatchAvgDuration": {"value": 1500, "representation": "ns"},
+	    "State": {"value": "running", "representation": ""}}]
+	}`), time.Minute, now, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.ingest(hsamples)
+
+	var b bytes.Buffer
+	if err := p.writeSamples(&b); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`heka_in_chan_capacity{kind="global",plugin="inputRecycleChan"} 100`,
+		`heka_in_chan_length{kind="global",plugin="inputRecycleChan"} 98`,
+		"# TYPE heka_process_message_count counter",
+		`heka_process_message_count{kind="filter",plugin="counter"} 42`,
+		"# TYPE heka_match_avg_duration_seconds gauge",
+		`heka_match_avg_duration_seconds{kind="filter",plugin="counter"} 1.5e-06`,
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("missing %q in\n%s", want, b.String())
+		}
+	}
+	if strings.Contains(b.String(), "heka_state") {
+		t.Errorf("non-numeric value exposed:\n%s", b.String())
+	}
+
+	if _, err := pipelineSamples([]byte(`{"globals": `), time.Minute, now, now); err == nil {
+		t.Error("expected an error for a truncated report")
+	}
+}
+
+func TestEnvelopeLabels(t *testing.T) {
+	config := new(PromOut).ConfigStruct().(*PromOutConfig)
+	config.EnvelopeLabels = map[string]string{
+		"host": "Hostname",
+		"dc":   "Fields[dc]",
+		"role": "Logger",
+	}
+	p := newTestPromOut(t, config)
+
+	recycled := make(chan *pipeline.PipelinePack, 3)
+	pack := pipeline.NewPipelinePack(recycled)
+	pack.Message.SetTimestamp(time.Now().UnixNano())
+	pack.Message.SetHostname("relay1")
+	pack.Message.SetLogger("aggregator")
+	f, _ := message.NewField("dc", "ams", "")
+	pack.Message.AddField(f)
+	pack.Message.SetPayload(`{"single": [
+	  {"name": "foo", "valuetype": "gauge", "value": 1, "labels": {"role": "web"}},
+	  {"name": "bar", "valuetype": "gauge", "value": 2}
+	]}`)
+	hsamples, err := p.decodePack(pack)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, h := range hsamples {
+		labels := sampleLabels(h)
+		if labels["host"] != "relay1" || labels["dc"] != "ams" {
+			t.Errorf("%s: envelope labels missing, got %v", h.single.Name, labels)
+		}
+	}
+	if role := sampleLabels(hsamples[0])["role"]; role != "web" {
+		t.Errorf("expected the payload's role to win, got %q", role)
+	}
+	if role := sampleLabels(hsamples[1])["role"]; role != "aggregator" {
+		t.Errorf("expected the envelope's role, got %q", role)
+	}
+
+	// attributes the message doesn't carry make no label, and envelope
+	// labels are held to declared ones like the payload's own
+	config.Metrics = map[string]*MetricConfig{
+		"baz": {Type: "gauge", Labels: []string{"role", "dc"}},
+	}
+	p = newTestPromOut(t, config)
+	pack = pipeline.NewPipelinePack(recycled)
+	pack.Message.SetTimestamp(time.Now().UnixNano())
+	pack.Message.SetLogger("aggregator")
+	pack.Message.SetPayload(`{"single": [{"name": "bar", "valuetype": "gauge", "value": 1}]}`)
+	if hsamples, err = p.decodePack(pack); err != nil {
+		t.Fatal(err)
+	}
+	if labels := sampleLabels(hsamples[0]); len(labels) != 1 {
+		t.Errorf("expected only the role label, got %v", labels)
+	}
+	pack = pipeline.NewPipelinePack(recycled)
+	pack.Message.SetTimestamp(time.Now().UnixNano())
+	pack.Message.SetHostname("relay1")
+	pack.Message.SetPayload(`{"single": [{"name": "baz", "value": 1}]}`)
+	if _, err = p.decodePack(pack); err == nil {
+		t.Error("expected the undeclared host label to be rejected")
+	}
+
+	config.EnvelopeLabels = map[string]string{"host": "Hostname()"}
+	if err := new(PromOut).setup(config); err == nil {
+		t.Error("expected an error for an unknown attribute")
+	}
+}
+
 /*
 func TestBufPool(t *testing.T) {
 	timestamp := time.Now()
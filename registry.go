@@ -0,0 +1,243 @@
+package prometheus
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MetricConfig declares a metric family in the plugin's TOML so producers
+// can leave out everything but name, labels and value.
+type MetricConfig struct {
+	Type    string
+	Help    string
+	Labels  []string
+	Buckets []float64
+	TTL     string `toml:"ttl"`
+}
+
+type metricMeta struct {
+	kind    string
+	help    string
+	labels  map[string]bool
+	buckets []float64
+	ttl     time.Duration
+}
+
+// ttlOverride applies a TTL to every metric whose name matches a glob, or a
+// regular expression when the pattern is wrapped in slashes.
+type ttlOverride struct {
+	pattern string
+	re      *regexp.Regexp
+	ttl     time.Duration
+}
+
+func (o *ttlOverride) match(name string) bool {
+	if o.re != nil {
+		return o.re.MatchString(name)
+	}
+	ok, _ := path.Match(o.pattern, name)
+	return ok
+}
+
+// metricRegistry holds the declared metric families. Samples of a declared
+// family inherit its help, type and TTL and are rejected when they
+// contradict it. A nil registry declares nothing.
+type metricRegistry struct {
+	metrics   map[string]*metricMeta
+	overrides []*ttlOverride
+
+	// replicaLabel is allowed on every declared family, ingest strips it
+	// off after the registry has checked the samples.
+	replicaLabel string
+}
+
+var metricKinds = map[string]bool{
+	"counter":   true,
+	"gauge":     true,
+	"untyped":   true,
+	"info":      true,
+	"stateset":  true,
+	"histogram": true,
+	"summary":   true,
+}
+
+func newMetricRegistry(config map[string]*MetricConfig, ttlOverrides map[string]string) (*metricRegistry, error) {
+	r := &metricRegistry{metrics: make(map[string]*metricMeta, len(config))}
+	for name, c := range config {
+		m := &metricMeta{
+			kind: strings.ToLower(c.Type),
+			help: c.Help,
+		}
+		if !metricKinds[m.kind] {
+			return nil, fmt.Errorf("metric %q: unknown type %q", name, c.Type)
+		}
+
+		if len(c.Labels) > 0 {
+			m.labels = make(map[string]bool, len(c.Labels))
+			for _, l := range c.Labels {
+				m.labels[l] = true
+			}
+		}
+
+		if len(c.Buckets) > 0 {
+			if m.kind != "histogram" {
+				return nil, fmt.Errorf("metric %q: buckets on a %s", name, m.kind)
+			}
+			m.buckets = append([]float64(nil), c.Buckets...)
+			sort.Float64s(m.buckets)
+		}
+
+		if c.TTL != "" {
+			var err error
+			if m.ttl, err = time.ParseDuration(c.TTL); err != nil {
+				return nil, fmt.Errorf("metric %q: %v", name, err)
+			}
+		}
+		r.metrics[name] = m
+	}
+
+	for pattern, ttl := range ttlOverrides {
+		o := &ttlOverride{pattern: pattern}
+		if len(pattern) > 1 && strings.HasPrefix(pattern, "/") &&
+			strings.HasSuffix(pattern, "/") {
+			re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+			if err != nil {
+				return nil, fmt.Errorf("ttl override %q: %v", pattern, err)
+			}
+			o.re = re
+		} else if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("ttl override %q: %v", pattern, err)
+		}
+
+		var err error
+		if o.ttl, err = time.ParseDuration(ttl); err != nil {
+			return nil, fmt.Errorf("ttl override %q: %v", pattern, err)
+		}
+		r.overrides = append(r.overrides, o)
+	}
+	// The longest pattern is taken to be the most specific and wins.
+	sort.Slice(r.overrides, func(i, j int) bool {
+		a, b := r.overrides[i].pattern, r.overrides[j].pattern
+		if len(a) != len(b) {
+			return len(a) > len(b)
+		}
+		return a < b
+	})
+	return r, nil
+}
+
+func (r *metricRegistry) lookup(name string) *metricMeta {
+	if r == nil {
+		return nil
+	}
+	return r.metrics[name]
+}
+
+// ttl returns how long samples of the named metric live when they don't say:
+// the TTL of its declared family, else of the first matching override, else
+// defaultTTL.
+func (r *metricRegistry) ttl(name string, defaultTTL time.Duration) time.Duration {
+	if r == nil {
+		return defaultTTL
+	}
+	if m := r.lookup(name); m != nil && m.ttl != 0 {
+		return m.ttl
+	}
+	for _, o := range r.overrides {
+		if o.match(name) {
+			return o.ttl
+		}
+	}
+	return defaultTTL
+}
+
+// check fills in a missing help and rejects a contradicting one or labels
+// the family doesn't allow, other than replica.
+func (m *metricMeta) check(name string, help *string, labels map[string]string, replica string) error {
+	if *help == "" {
+		*help = m.help
+	} else if m.help != "" && *help != m.help {
+		return fmt.Errorf(
+			"metric %q sent with help %q but declared with %q", name, *help, m.help,
+		)
+	}
+
+	if m.labels == nil {
+		return nil
+	}
+	for l := range labels {
+		if !m.labels[l] && l != replica {
+			return fmt.Errorf("metric %q: label %q is not declared", name, l)
+		}
+	}
+	return nil
+}
+
+func (m *metricMeta) checkKind(name, kind string) error {
+	if m.kind != kind {
+		return fmt.Errorf(
+			"metric %q sent as %s but declared as %s", name, kind, m.kind,
+		)
+	}
+	return nil
+}
+
+func (r *metricRegistry) single(c *ConstMetric) error {
+	m := r.lookup(c.Name)
+	if m == nil {
+		return nil
+	}
+	if c.ValueType == "" {
+		c.ValueType = m.kind
+	}
+	if err := m.checkKind(c.Name, strings.ToLower(c.ValueType)); err != nil {
+		return err
+	}
+	return m.check(c.Name, &c.Help, c.Labels, r.replicaLabel)
+}
+
+func (r *metricRegistry) summary(c *ConstSummary) error {
+	m := r.lookup(c.Name)
+	if m == nil {
+		return nil
+	}
+	if err := m.checkKind(c.Name, "summary"); err != nil {
+		return err
+	}
+	return m.check(c.Name, &c.Help, c.Labels, r.replicaLabel)
+}
+
+// histogram also holds the parsed buckets of c to the declared layout.
+func (r *metricRegistry) histogram(c *ConstHistogram) error {
+	m := r.lookup(c.Name)
+	if m == nil {
+		return nil
+	}
+	if err := m.checkKind(c.Name, "histogram"); err != nil {
+		return err
+	}
+	if err := m.check(c.Name, &c.Help, c.Labels, r.replicaLabel); err != nil {
+		return err
+	}
+
+	if m.buckets == nil {
+		return nil
+	}
+	if len(c._buckets) != len(m.buckets) {
+		return fmt.Errorf(
+			"histogram %q: buckets don't match the declared %v", c.Name, m.buckets,
+		)
+	}
+	for _, b := range m.buckets {
+		if _, ok := c._buckets[b]; !ok {
+			return fmt.Errorf(
+				"histogram %q: buckets don't match the declared %v", c.Name, m.buckets,
+			)
+		}
+	}
+	return nil
+}
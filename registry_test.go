@@ -0,0 +1,113 @@
+package prometheus
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMetricRegistry(t *testing.T) {
+	registry, err := newMetricRegistry(map[string]*MetricConfig{
+		"requests": {
+			Type:   "counter",
+			Help:   "requests served",
+			Labels: []string{"host", "code"},
+			TTL:    "1h",
+		},
+		"latency": {
+			Type:    "histogram",
+			Help:    "request latency",
+			Buckets: []float64{1, 0.1},
+		},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	hsamples, err := newHekaSampleScalar([]byte(`
+{
+  "single": [{"name": "requests", "value": 10, "labels": {"host": "web1"}}],
+  "histogram": [{"name": "latency", "count": 3, "sum": 1, "buckets": {"0.1": 1, "1": 3}}]
+}`), registry, time.Minute, now, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := hsamples[0].single
+	if c.Help != "requests served" || c.ValueType != "counter" {
+		t.Errorf("declared help and type not filled in: %+v", c)
+	}
+	if !hsamples[0].expires.Equal(now.Add(time.Hour)) {
+		t.Errorf("declared ttl not applied: %v", hsamples[0].expires)
+	}
+	if hsamples[1].hist.Help != "request latency" {
+		t.Errorf("declared help not filled in: %+v", hsamples[1].hist)
+	}
+	if !hsamples[1].expires.Equal(now.Add(time.Minute)) {
+		t.Errorf("default ttl not applied: %v", hsamples[1].expires)
+	}
+
+	for _, payload := range []string{
+		`{"single": [{"name": "requests", "valuetype": "gauge", "value": 1}]}`,
+		`{"single": [{"name": "requests", "help": "something else", "value": 1}]}`,
+		`{"single": [{"name": "requests", "value": 1, "labels": {"path": "/"}}]}`,
+		`{"summary": [{"name": "requests", "count": 1, "sum": 1}]}`,
+		`{"histogram": [{"name": "latency", "count": 1, "sum": 1, "buckets": {"0.5": 1}}]}`,
+	} {
+		if _, err := newHekaSampleScalar([]byte(payload), registry, time.Minute, now, now); err == nil {
+			t.Errorf("payload should have been rejected: %s", payload)
+		}
+	}
+
+	if _, err := newMetricRegistry(map[string]*MetricConfig{
+		"foo": {Type: "gauge", Buckets: []float64{1}},
+	}, nil); err == nil {
+		t.Errorf("buckets on a gauge should have been rejected")
+	}
+}
+
+func TestTTLOverrides(t *testing.T) {
+	registry, err := newMetricRegistry(map[string]*MetricConfig{
+		"batch_declared": {Type: "gauge", TTL: "2h"},
+	}, map[string]string{
+		"batch_*":         "4h",
+		"batch_nightly_*": "24h",
+		"/_churn(_|$)/":   "5s",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]time.Duration{
+		"batch_declared":   2 * time.Hour,
+		"batch_runs":       4 * time.Hour,
+		"batch_nightly_gc": 24 * time.Hour,
+		"conn_churn":       5 * time.Second,
+		"conn_churn_total": 5 * time.Second,
+		"conn_churned":     time.Minute,
+		"something_else":   time.Minute,
+	} {
+		if got := registry.ttl(name, time.Minute); got != want {
+			t.Errorf("%s: expected ttl %v, got %v", name, want, got)
+		}
+	}
+
+	// info metrics are looked up under the name they are exposed with
+	now := time.Now()
+	hsamples, err := newHekaSampleScalar([]byte(`{"single": [
+	  {"name": "batch", "valuetype": "info", "labels": {"version": "1"}}
+	]}`), registry, time.Minute, now, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hsamples[0].expires.Sub(now); got != 4*time.Hour {
+		t.Errorf("batch_info: expected ttl 4h, got %v", got)
+	}
+
+	if _, err := newMetricRegistry(nil, map[string]string{"/(/": "1s"}); err == nil {
+		t.Errorf("bad regex should have been rejected")
+	}
+	if _, err := newMetricRegistry(nil, map[string]string{"foo_*": "soon"}); err == nil {
+		t.Errorf("bad duration should have been rejected")
+	}
+}
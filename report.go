@@ -0,0 +1,52 @@
+package prometheus
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/mozilla-services/heka/message"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// ReportMsg adds the plugin's own state to hekad's report messages, and so to
+// its dashboard.
+func (p *PromOut) ReportMsg(msg *message.Message) error {
+	message.NewInt64Field(msg, "SeriesCount", int64(p.samples.len()), "count")
+	message.NewInt64Field(msg, "SuccessCount", int64(counterValue(p.inSuccess)), "count")
+	message.NewInt64Field(msg, "FailureCount", int64(counterValue(p.inFailure)), "count")
+	message.NewInt64Field(msg, "ExpiredCount", int64(counterValue(p.seriesExpired)), "count")
+
+	lastScrape := "never"
+	if ns := atomic.LoadInt64(&p.lastScrape); ns != 0 {
+		lastScrape = time.Unix(0, ns).Format(time.RFC3339)
+	}
+	f, err := message.NewField("LastScrape", lastScrape, "")
+	if err != nil {
+		return err
+	}
+	msg.AddField(f)
+	return nil
+}
+
+// scraped records the time of the latest scrape.
+func (p *PromOut) scraped(t time.Time) {
+	atomic.StoreInt64(&p.lastScrape, t.UnixNano())
+}
+
+// recordScrapes records a scrape for every request to h. Collect doesn't
+// record them itself since with render_interval it runs on the renderer's
+// ticker rather than for scrapes.
+func (p *PromOut) recordScrapes(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		p.scraped(time.Now())
+		h.ServeHTTP(w, req)
+	})
+}
+
+func counterValue(c prometheus.Counter) float64 {
+	m := &dto.Metric{}
+	c.Write(m)
+	return m.GetCounter().GetValue()
+}
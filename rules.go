@@ -0,0 +1,311 @@
+package prometheus
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mozilla-services/heka/message"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// RuleConfig derives a metric straight from the messages it matches, no
+// payload needed. Counters count matched messages, or add up Field when it is
+// set, gauges take the value of Field and histograms observe it. Labels map
+// label names to Hostname, Logger, Type, EnvVersion, Pid or Fields[name].
+//
+// With Regex set the rule reads the payload instead: lines it doesn't match
+// are skipped, Field names the capture group holding the value and every
+// other named group becomes a label.
+//
+// A rule deriving a metric declared in [metrics] takes its type, help and
+// buckets from there when it leaves them out, and its samples are held to
+// the declared labels like any other.
+//
+// Derived metrics live for TTL after the last message that touched them, or
+// for the TTL declared for the metric or set in ttl_overrides. Without any
+// they never expire: default_ttl would reset counters of events rarer than
+// it to zero every time.
+type RuleConfig struct {
+	MessageMatcher string `toml:"message_matcher"`
+	Type           string
+	Help           string
+	Field          string
+	Regex          string
+	Buckets        []float64
+	Labels         map[string]string
+	TTL            string `toml:"ttl"`
+}
+
+// neverExpires is the expiry of derived samples without a TTL.
+var neverExpires = time.Unix(1<<62, 0)
+
+type rule struct {
+	name    string
+	help    string
+	kind    string
+	matcher *message.MatcherSpecification
+	field   string
+	re      *regexp.Regexp
+	buckets []float64
+	labels  messageLabels
+	ttl     time.Duration
+
+	registry *metricRegistry
+}
+
+// rules are evaluated in name order against every message, before its
+// payload is looked at. A nil rules matches nothing.
+type rules []*rule
+
+func newRules(config map[string]*RuleConfig, registry *metricRegistry) (rules, error) {
+	var rs rules
+	for name, c := range config {
+		r := &rule{
+			name:     name,
+			help:     c.Help,
+			kind:     strings.ToLower(c.Type),
+			field:    c.Field,
+			ttl:      registry.ttl(name, 0),
+			registry: registry,
+		}
+		if c.TTL != "" {
+			ttl, err := time.ParseDuration(c.TTL)
+			if err != nil {
+				return nil, fmt.Errorf("rule %q: %v", name, err)
+			}
+			if ttl <= 0 {
+				return nil, fmt.Errorf("rule %q: ttl %s is not positive", name, c.TTL)
+			}
+			r.ttl = ttl
+		}
+		buckets := c.Buckets
+		if m := registry.lookup(name); m != nil {
+			if r.kind == "" {
+				r.kind = m.kind
+			}
+			if err := m.checkKind(name, r.kind); err != nil {
+				return nil, fmt.Errorf("rule %q: %v", name, err)
+			}
+			if r.help == "" {
+				r.help = m.help
+			} else if m.help != "" && r.help != m.help {
+				return nil, fmt.Errorf("rule %q: help %q but declared with %q", name, r.help, m.help)
+			}
+			if len(buckets) == 0 {
+				buckets = m.buckets
+			} else if m.buckets != nil && !sameBounds(buckets, m.buckets) {
+				return nil, fmt.Errorf("rule %q: buckets %v but declared with %v", name, buckets, m.buckets)
+			}
+		}
+		if r.help == "" {
+			r.help = name
+		}
+
+		switch r.kind {
+		case "counter":
+		case "gauge":
+			if r.field == "" {
+				return nil, fmt.Errorf("rule %q: a gauge needs a field", name)
+			}
+		case "histogram":
+			if r.field == "" {
+				return nil, fmt.Errorf("rule %q: a histogram needs a field", name)
+			}
+			r.buckets = append([]float64(nil), buckets...)
+			if len(r.buckets) == 0 {
+				r.buckets = prometheus.DefBuckets
+			}
+			sort.Float64s(r.buckets)
+		default:
+			return nil, fmt.Errorf("rule %q: unknown type %q", name, c.Type)
+		}
+		if len(c.Buckets) > 0 && r.kind != "histogram" {
+			return nil, fmt.Errorf("rule %q: buckets on a %s", name, r.kind)
+		}
+
+		var err error
+		if c.Regex != "" {
+			if r.re, err = regexp.Compile(c.Regex); err != nil {
+				return nil, fmt.Errorf("rule %q: %v", name, err)
+			}
+			if r.field != "" && !hasGroup(r.re, r.field) {
+				return nil, fmt.Errorf("rule %q: no group named %q", name, r.field)
+			}
+		}
+		if r.matcher, err = message.CreateMatcherSpecification(c.MessageMatcher); err != nil {
+			return nil, fmt.Errorf("rule %q: %v", name, err)
+		}
+		if r.labels, err = newMessageLabels(c.Labels); err != nil {
+			return nil, fmt.Errorf("rule %q: %v", name, err)
+		}
+		rs = append(rs, r)
+	}
+	sort.Slice(rs, func(i, j int) bool { return rs[i].name < rs[j].name })
+	return rs, nil
+}
+
+// sameBounds reports whether bounds, in any order, are the sorted declared
+// ones.
+func sameBounds(bounds, declared []float64) bool {
+	if len(bounds) != len(declared) {
+		return false
+	}
+	sorted := append([]float64(nil), bounds...)
+	sort.Float64s(sorted)
+	for i, b := range sorted {
+		if b != declared[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// hasGroup reports whether re has a capture group called name.
+func hasGroup(re *regexp.Regexp, name string) bool {
+	for _, n := range re.SubexpNames() {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// derive runs msg through every rule, starting the labels of every sample
+// from envelope. matched reports whether the matcher of
+// any rule matched msg, in which case its payload is not decoded, even when
+// the rule's regex doesn't match it. Samples of the rules that could be
+// applied are returned along with the first error.
+func (rs rules) derive(msg *message.Message, envelope map[string]string, timestamp, base time.Time) (hsamples []*hekaSample, matched bool, err error) {
+	for _, r := range rs {
+		if !r.matcher.Match(msg) {
+			continue
+		}
+		matched = true
+
+		h, rerr := r.apply(msg, envelope, timestamp, base)
+		if rerr != nil {
+			if err == nil {
+				err = fmt.Errorf("rule %q: %v", r.name, rerr)
+			}
+			continue
+		}
+		if h != nil {
+			hsamples = append(hsamples, h)
+		}
+	}
+	return hsamples, matched, err
+}
+
+// apply derives a sample from msg, or nothing when the rule has a regex the
+// payload doesn't match. Labels of the rule win over envelope.
+func (r *rule) apply(msg *message.Message, envelope map[string]string, timestamp, base time.Time) (*hekaSample, error) {
+	var (
+		value  = 1.0
+		labels = make(map[string]string, len(envelope)+len(r.labels))
+		err    error
+	)
+	for k, v := range envelope {
+		labels[k] = v
+	}
+	if r.re != nil {
+		groups := r.re.FindStringSubmatch(msg.GetPayload())
+		if groups == nil {
+			return nil, nil
+		}
+		for i, name := range r.re.SubexpNames() {
+			switch name {
+			case "":
+			case r.field:
+				if value, err = strconv.ParseFloat(groups[i], 64); err != nil {
+					return nil, err
+				}
+			default:
+				labels[name] = groups[i]
+			}
+		}
+	} else if r.field != "" {
+		if value, err = fieldNumber(msg, r.field); err != nil {
+			return nil, err
+		}
+	}
+
+	r.labels.read(msg, labels)
+
+	if r.kind == "histogram" {
+		c := &ConstHistogram{
+			Count:    1,
+			Sum:      value,
+			_buckets: make(map[float64]uint64, len(r.buckets)),
+			Name:     r.name,
+			Labels:   labels,
+			Help:     r.help,
+		}
+		for _, b := range r.buckets {
+			if value <= b {
+				c._buckets[b] = 1
+			} else {
+				c._buckets[b] = 0
+			}
+		}
+		if err := r.registry.histogram(c); err != nil {
+			return nil, err
+		}
+		return &hekaSample{
+			hist:       c,
+			expires:    r.expires(base),
+			timestamp:  timestamp,
+			accumulate: true,
+		}, nil
+	}
+
+	c := &ConstMetric{
+		Value:     value,
+		Name:      r.name,
+		Labels:    labels,
+		Help:      r.help,
+		ValueType: r.kind,
+		valueType: prometheus.GaugeValue,
+	}
+	if err := r.registry.single(c); err != nil {
+		return nil, err
+	}
+	h := &hekaSample{
+		single:    c,
+		expires:   r.expires(base),
+		timestamp: timestamp,
+	}
+	if r.kind == "counter" {
+		c.valueType = prometheus.CounterValue
+		h.accumulate = true
+	}
+	return h, nil
+}
+
+// expires returns when a sample derived at base expires.
+func (r *rule) expires(base time.Time) time.Time {
+	if r.ttl == 0 {
+		return neverExpires
+	}
+	return base.Add(r.ttl)
+}
+
+// accumulate adds what old has counted so far to h, which only carries what
+// a single message added.
+func accumulate(old, h *hekaSample) {
+	switch {
+	case h.single != nil && old.single != nil:
+		h.single.Value += old.single.Value
+	case h.hist != nil && old.hist != nil:
+		h.hist.Count += old.hist.Count
+		h.hist.Sum += old.hist.Sum
+		for b, n := range old.hist._buckets {
+			if _, ok := h.hist._buckets[b]; ok {
+				h.hist._buckets[b] += n
+			}
+		}
+	}
+}
@@ -0,0 +1,332 @@
+package prometheus
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mozilla-services/heka/message"
+	"github.com/mozilla-services/heka/pipeline"
+)
+
+func accessLogPack(recycled chan *pipeline.PipelinePack, host, status string, requestTime float64) *pipeline.PipelinePack {
+	pack := pipeline.NewPipelinePack(recycled)
+	pack.Message.SetTimestamp(time.Now().UnixNano())
+	pack.Message.SetType("nginx.access")
+	pack.Message.SetHostname(host)
+	pack.Message.SetPayload("GET / HTTP/1.1")
+	f, _ := message.NewField("status", status, "")
+	pack.Message.AddField(f)
+	f, _ = message.NewField("request_time", requestTime, "s")
+	pack.Message.AddField(f)
+	return pack
+}
+
+func TestRules(t *testing.T) {
+	config := new(PromOut).ConfigStruct().(*PromOutConfig)
+	config.Rules = map[string]*RuleConfig{
+		"nginx_requests_total": {
+			MessageMatcher: "Type == 'nginx.access'",
+			Type:           "counter",
+			Help:           "requests served",
+			Labels:         map[string]string{"host": "Hostname", "status": "Fields[status]"},
+		},
+		"nginx_request_seconds": {
+			MessageMatcher: "Type == 'nginx.access'",
+			Type:           "histogram",
+			Field:          "request_time",
+			Buckets:        []float64{0.1, 1},
+		},
+	}
+	p := newTestPromOut(t, config)
+
+	recycled := make(chan *pipeline.PipelinePack, 10)
+	for _, pack := range []*pipeline.PipelinePack{
+		accessLogPack(recycled, "web1", "200", 0.0625),
+		accessLogPack(recycled, "web1", "200", 0.5),
+		accessLogPack(recycled, "web1", "500", 2),
+		accessLogPack(recycled, "web2", "200", 0.0625),
+	} {
+		hsamples, err := p.decodePack(pack)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.ingest(hsamples)
+	}
+	if n := len(recycled); n != 4 {
+		t.Errorf("expected 4 recycled packs, got %d", n)
+	}
+
+	var b bytes.Buffer
+	if err := p.writeSamples(&b); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# TYPE nginx_requests_total counter",
+		`nginx_requests_total{host="web1",status="200"} 2`,
+		`nginx_requests_total{host="web1",status="500"} 1`,
+		`nginx_requests_total{host="web2",status="200"} 1`,
+		"# TYPE nginx_request_seconds histogram",
+		`nginx_request_seconds_bucket{le="0.1"} 2`,
+		`nginx_request_seconds_bucket{le="1"} 3`,
+		`nginx_request_seconds_bucket{le="+Inf"} 4`,
+		"nginx_request_seconds_sum 2.625",
+		"nginx_request_seconds_count 4",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("missing %q in\n%s", want, b.String())
+		}
+	}
+
+	// messages no rule matches still have their payload decoded
+	pack := pipeline.NewPipelinePack(recycled)
+	pack.Message.SetType("metrics")
+	pack.Message.SetTimestamp(time.Now().UnixNano())
+	pack.Message.SetPayload(`{"single": [{"name": "foo", "valuetype": "gauge", "value": 1}]}`)
+	hsamples, err := p.decodePack(pack)
+	if err != nil || len(hsamples) != 1 {
+		t.Errorf("expected the payload to be decoded, got %d samples, %v", len(hsamples), err)
+	}
+}
+
+func TestRegexRules(t *testing.T) {
+	config := new(PromOut).ConfigStruct().(*PromOutConfig)
+	config.Rules = map[string]*RuleConfig{
+		"app_queries_total": {
+			MessageMatcher: "Logger == 'app'",
+			Type:           "counter",
+			Regex:          `query (?P<table>\w+) took`,
+			Labels:         map[string]string{"host": "Hostname"},
+		},
+		"app_query_seconds": {
+			MessageMatcher: "Logger == 'app'",
+			Type:           "histogram",
+			Regex:          `query (?P<table>\w+) took (?P<seconds>[0-9.]+)s`,
+			Field:          "seconds",
+			Buckets:        []float64{1},
+		},
+	}
+	p := newTestPromOut(t, config)
+
+	recycled := make(chan *pipeline.PipelinePack, 10)
+	for _, line := range []string{
+		"query users took 0.5s",
+		"query users took 1.5s",
+		"query orders took 0.25s",
+		"connection reset by peer",
+	} {
+		pack := pipeline.NewPipelinePack(recycled)
+		pack.Message.SetTimestamp(time.Now().UnixNano())
+		pack.Message.SetLogger("app")
+		pack.Message.SetHostname("db1")
+		pack.Message.SetPayload(line)
+		hsamples, err := p.decodePack(pack)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.ingest(hsamples)
+	}
+
+	var b bytes.Buffer
+	if err := p.writeSamples(&b); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`app_queries_total{host="db1",table="users"} 2`,
+		`app_queries_total{host="db1",table="orders"} 1`,
+		`app_query_seconds_bucket{table="users",le="1"} 1`,
+		`app_query_seconds_sum{table="users"} 2`,
+		`app_query_seconds_count{table="orders"} 1`,
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("missing %q in\n%s", want, b.String())
+		}
+	}
+	if n := counterValue(p.inFailure); n != 0 {
+		t.Errorf("expected unmatched lines to be skipped quietly, got %v failures", n)
+	}
+
+	if _, err := newRules(map[string]*RuleConfig{"foo": {
+		MessageMatcher: "TRUE", Type: "gauge", Regex: `(?P<v>\d+)`, Field: "value",
+	}}, nil); err == nil {
+		t.Error("expected an error for a field that is not a group")
+	}
+}
+
+func TestRuleErrors(t *testing.T) {
+	for name, c := range map[string]*RuleConfig{
+		"no matcher":     {Type: "counter"},
+		"unknown type":   {MessageMatcher: "TRUE", Type: "summary"},
+		"gauge no field": {MessageMatcher: "TRUE", Type: "gauge"},
+		"counter bucket": {MessageMatcher: "TRUE", Type: "counter", Buckets: []float64{1}},
+		"bad label":      {MessageMatcher: "TRUE", Type: "counter", Labels: map[string]string{"a": "Severity[x]"}},
+		"bad ttl":        {MessageMatcher: "TRUE", Type: "counter", TTL: "soon"},
+		"zero ttl":       {MessageMatcher: "TRUE", Type: "counter", TTL: "0s"},
+	} {
+		if _, err := newRules(map[string]*RuleConfig{"foo": c}, nil); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	rs, err := newRules(map[string]*RuleConfig{
+		"foo": {MessageMatcher: "TRUE", Type: "gauge", Field: "missing"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	hsamples, matched, err := rs.derive(&message.Message{}, nil, now, now)
+	if !matched || err == nil || len(hsamples) != 0 {
+		t.Errorf("expected a matched rule failing on its field, got %v, %v, %v",
+			len(hsamples), matched, err)
+	}
+}
+
+func TestRulesRegistry(t *testing.T) {
+	config := new(PromOut).ConfigStruct().(*PromOutConfig)
+	config.Metrics = map[string]*MetricConfig{
+		"nginx_requests_total": {
+			Type:   "counter",
+			Help:   "requests served",
+			Labels: []string{"host"},
+		},
+		"nginx_request_seconds": {
+			Type:    "histogram",
+			Help:    "request latency",
+			Buckets: []float64{0.5},
+		},
+	}
+	config.Rules = map[string]*RuleConfig{
+		"nginx_requests_total": {
+			MessageMatcher: "Type == 'nginx.access'",
+			Labels:         map[string]string{"host": "Hostname"},
+		},
+		"nginx_request_seconds": {
+			MessageMatcher: "Type == 'nginx.access'",
+			Type:           "histogram",
+			Field:          "request_time",
+		},
+	}
+	p := newTestPromOut(t, config)
+
+	recycled := make(chan *pipeline.PipelinePack, 10)
+	hsamples, err := p.decodePack(accessLogPack(recycled, "web1", "200", 0.25))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.ingest(hsamples)
+
+	var b bytes.Buffer
+	if err := p.writeSamples(&b); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# HELP nginx_requests_total requests served",
+		`nginx_requests_total{host="web1"} 1`,
+		"# HELP nginx_request_seconds request latency",
+		`nginx_request_seconds_bucket{le="0.5"} 1`,
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("missing %q in\n%s", want, b.String())
+		}
+	}
+
+	for name, c := range map[string]struct {
+		metric string
+		rule   *RuleConfig
+	}{
+		"kind": {"nginx_requests_total",
+			&RuleConfig{MessageMatcher: "TRUE", Type: "gauge", Field: "request_time"}},
+		"help": {"nginx_requests_total",
+			&RuleConfig{MessageMatcher: "TRUE", Help: "something else"}},
+		"counter buckets": {"nginx_requests_total",
+			&RuleConfig{MessageMatcher: "TRUE", Type: "counter", Buckets: []float64{1}}},
+		"other buckets": {"nginx_request_seconds",
+			&RuleConfig{MessageMatcher: "TRUE", Field: "request_time", Buckets: []float64{0.5, 1}}},
+	} {
+		if _, err := newRules(map[string]*RuleConfig{c.metric: c.rule},
+			p.registry); err == nil {
+			t.Errorf("%s: expected an error contradicting the declared metric", name)
+		}
+	}
+
+	// labels the declared metric doesn't allow fail the rule
+	rs, err := newRules(map[string]*RuleConfig{"nginx_requests_total": {
+		MessageMatcher: "TRUE", Labels: map[string]string{"status": "Fields[status]"},
+	}}, p.registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := &message.Message{}
+	f, _ := message.NewField("status", "200", "")
+	msg.AddField(f)
+	now := time.Now()
+	if _, _, err := rs.derive(msg, nil, now, now); err == nil {
+		t.Error("expected an undeclared label to be rejected")
+	}
+}
+
+func TestRuleTTL(t *testing.T) {
+	registry, err := newMetricRegistry(nil, map[string]string{"slow_*": "1h"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs, err := newRules(map[string]*RuleConfig{
+		"errors_total": {MessageMatcher: "TRUE", Type: "counter"},
+		"slow_total":   {MessageMatcher: "TRUE", Type: "counter"},
+		"fast_total":   {MessageMatcher: "TRUE", Type: "counter", TTL: "10m"},
+	}, registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	hsamples, _, err := rs.derive(&message.Message{}, nil, now, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]time.Time{
+		"errors_total": neverExpires,
+		"slow_total":   now.Add(time.Hour),
+		"fast_total":   now.Add(10 * time.Minute),
+	}
+	for _, h := range hsamples {
+		if !h.expires.Equal(want[h.single.Name]) {
+			t.Errorf("%s: expected to expire at %v, got %v",
+				h.single.Name, want[h.single.Name], h.expires)
+		}
+	}
+}
+
+func TestRulesExpiredCounter(t *testing.T) {
+	config := new(PromOut).ConfigStruct().(*PromOutConfig)
+	config.Rules = map[string]*RuleConfig{
+		"nginx_requests_total": {
+			MessageMatcher: "Type == 'nginx.access'",
+			Type:           "counter",
+		},
+	}
+	p := newTestPromOut(t, config)
+
+	recycled := make(chan *pipeline.PipelinePack, 10)
+	ingest := func() {
+		hsamples, err := p.decodePack(accessLogPack(recycled, "web1", "200", 0.25))
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.ingest(hsamples)
+	}
+	ingest()
+	ingest()
+	// expire the stored counter without sweeping it
+	p.samples.each(func(h *hekaSample) { h.expires = time.Now().Add(-time.Second) })
+	ingest()
+
+	var b bytes.Buffer
+	if err := p.writeSamples(&b); err != nil {
+		t.Fatal(err)
+	}
+	if want := "nginx_requests_total 1\n"; !strings.Contains(b.String(), want) {
+		t.Errorf("expected the counter to start over, got\n%s", b.String())
+	}
+}
@@ -0,0 +1,127 @@
+package prometheus
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mozilla-services/heka/message"
+)
+
+// messageSource reads one attribute of a heka message as a label value.
+type messageSource func(msg *message.Message) (string, bool)
+
+// newMessageSource resolves spec, one of Hostname, Logger, Type, EnvVersion,
+// Pid or Fields[name], to the attribute it names.
+func newMessageSource(spec string) (messageSource, error) {
+	switch spec {
+	case "Hostname":
+		return headerSource((*message.Message).GetHostname), nil
+	case "Logger":
+		return headerSource((*message.Message).GetLogger), nil
+	case "Type":
+		return headerSource((*message.Message).GetType), nil
+	case "EnvVersion":
+		return headerSource((*message.Message).GetEnvVersion), nil
+	case "Pid":
+		return func(msg *message.Message) (string, bool) {
+			return strconv.Itoa(int(msg.GetPid())), msg.Pid != nil
+		}, nil
+	}
+
+	name, ok := fieldName(spec)
+	if !ok {
+		return nil, fmt.Errorf("unknown message attribute %q", spec)
+	}
+	return func(msg *message.Message) (string, bool) {
+		v, ok := msg.GetFieldValue(name)
+		if !ok {
+			return "", false
+		}
+		switch v := v.(type) {
+		case string:
+			return v, true
+		case []byte:
+			return string(v), true
+		case float64:
+			return strconv.FormatFloat(v, 'g', -1, 64), true
+		default:
+			return fmt.Sprint(v), true
+		}
+	}, nil
+}
+
+// messageLabel takes the value of a label from a message.
+type messageLabel struct {
+	name   string
+	source messageSource
+}
+
+// messageLabels map label names to the message attributes they are read
+// from, see newMessageSource.
+type messageLabels []messageLabel
+
+func newMessageLabels(config map[string]string) (messageLabels, error) {
+	var ls messageLabels
+	for name, spec := range config {
+		source, err := newMessageSource(spec)
+		if err != nil {
+			return nil, fmt.Errorf("label %q: %v", name, err)
+		}
+		ls = append(ls, messageLabel{name, source})
+	}
+	sort.Slice(ls, func(i, j int) bool { return ls[i].name < ls[j].name })
+	return ls, nil
+}
+
+// read adds the labels read from msg to into, leaving out the attributes msg
+// doesn't carry or carries empty.
+func (ls messageLabels) read(msg *message.Message, into map[string]string) {
+	for _, l := range ls {
+		if v, ok := l.source(msg); ok && v != "" {
+			into[l.name] = v
+		}
+	}
+}
+
+func headerSource(get func(*message.Message) string) messageSource {
+	return func(msg *message.Message) (string, bool) {
+		v := get(msg)
+		return v, v != ""
+	}
+}
+
+// fieldName extracts name from a Fields[name] spec.
+func fieldName(spec string) (string, bool) {
+	if !strings.HasPrefix(spec, "Fields[") || !strings.HasSuffix(spec, "]") {
+		return "", false
+	}
+	name := spec[len("Fields[") : len(spec)-1]
+	return name, name != ""
+}
+
+// fieldNumber reads the named field of msg as a number, numeric strings
+// included since log decoders often leave them as such.
+func fieldNumber(msg *message.Message, name string) (float64, error) {
+	v, ok := msg.GetFieldValue(name)
+	if !ok {
+		return 0, fmt.Errorf("field %q is missing", name)
+	}
+	switch v := v.(type) {
+	case float64:
+		return v, nil
+	case int64:
+		return float64(v), nil
+	case bool:
+		if v {
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		return strconv.ParseFloat(v, 64)
+	case []byte:
+		return strconv.ParseFloat(string(v), 64)
+	}
+	return 0, fmt.Errorf("field %q is not numeric", name)
+}
@@ -0,0 +1,158 @@
+package prometheus
+
+import (
+	"container/heap"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// sampleStore holds the stored samples sharded by series key, each shard
+// with its own lock and expiry heap, so that ingestion, sweeping and scrapes
+// only contend on the shard they are touching.
+type sampleStore struct {
+	shards []*storeShard
+	// lockWait, when set, observes how long writers wait for a shard.
+	lockWait prometheus.Observer
+}
+
+type storeShard struct {
+	sync.RWMutex
+	samples map[uint64]*hekaSample
+	expiry  expiryHeap
+}
+
+func newSampleStore(shards int) *sampleStore {
+	if shards < 1 {
+		shards = 1
+	}
+	s := &sampleStore{shards: make([]*storeShard, shards)}
+	for i := range s.shards {
+		s.shards[i] = &storeShard{samples: make(map[uint64]*hekaSample)}
+	}
+	return s
+}
+
+func (s *sampleStore) shard(key uint64) *storeShard {
+	return s.shards[key%uint64(len(s.shards))]
+}
+
+// lock write locks sh, observing the wait.
+func (s *sampleStore) lock(sh *storeShard) {
+	if s.lockWait == nil {
+		sh.Lock()
+		return
+	}
+	start := time.Now()
+	sh.Lock()
+	s.lockWait.Observe(time.Since(start).Seconds())
+}
+
+// errKeyCollision is returned for a series whose key is already taken by
+// another series.
+var errKeyCollision = errors.New("series key taken by another series")
+
+// get returns the sample stored for the series of h, if any.
+func (s *sampleStore) get(h *hekaSample) (*hekaSample, error) {
+	sh := s.shard(h.key)
+	sh.RLock()
+	old, ok := sh.samples[h.key]
+	sh.RUnlock()
+	if ok && !sameSeries(old, h) {
+		return nil, errKeyCollision
+	}
+	return old, nil
+}
+
+// put stores h under its key and returns the sample it replaced, if any. A
+// sample of another series stored under the same key is left in place. h is
+// a freshly decoded sample, never one the store already holds.
+func (s *sampleStore) put(h *hekaSample) (*hekaSample, error) {
+	sh := s.shard(h.key)
+	s.lock(sh)
+	defer sh.Unlock()
+	old, ok := sh.samples[h.key]
+	if ok {
+		if !sameSeries(old, h) {
+			return nil, errKeyCollision
+		}
+		sh.expiry.replace(old, h)
+	} else {
+		heap.Push(&sh.expiry, h)
+	}
+	sh.samples[h.key] = h
+	return old, nil
+}
+
+// remove drops and returns the sample stored under key, if any.
+func (s *sampleStore) remove(key uint64) *hekaSample {
+	sh := s.shard(key)
+	s.lock(sh)
+	h, ok := sh.samples[key]
+	if ok {
+		heap.Remove(&sh.expiry, h.index)
+		delete(sh.samples, key)
+	}
+	sh.Unlock()
+	return h
+}
+
+// extend keeps the sample stored under key until at least expires. The
+// sample is replaced by an updated copy, stored samples are never modified.
+func (s *sampleStore) extend(key uint64, expires time.Time) {
+	sh := s.shard(key)
+	s.lock(sh)
+	if h, ok := sh.samples[key]; ok && h.expires.Before(expires) {
+		c := *h
+		c.expires = expires
+		sh.expiry.replace(h, &c)
+		sh.samples[key] = &c
+	}
+	sh.Unlock()
+}
+
+// sweep drops and returns the samples that have expired by now.
+func (s *sampleStore) sweep(now time.Time) []*hekaSample {
+	var expired []*hekaSample
+	for _, sh := range s.shards {
+		s.lock(sh)
+		due := sh.expiry.due(now)
+		for _, h := range due {
+			delete(sh.samples, h.key)
+		}
+		sh.Unlock()
+		expired = append(expired, due...)
+	}
+	return expired
+}
+
+// each calls f with every stored sample, one shard at a time. Shards are
+// copied before calling f so that f can block without holding up ingestion.
+func (s *sampleStore) each(f func(*hekaSample)) {
+	var samples []*hekaSample
+	for _, sh := range s.shards {
+		sh.RLock()
+		samples = samples[:0]
+		for _, h := range sh.samples {
+			samples = append(samples, h)
+		}
+		sh.RUnlock()
+
+		for _, h := range samples {
+			f(h)
+		}
+	}
+}
+
+// len returns the number of stored samples.
+func (s *sampleStore) len() int {
+	n := 0
+	for _, sh := range s.shards {
+		sh.RLock()
+		n += len(sh.samples)
+		sh.RUnlock()
+	}
+	return n
+}
@@ -0,0 +1,112 @@
+package prometheus
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func storeBenchSamples(b *testing.B, n int) []*hekaSample {
+	hsamples := make([]*hekaSample, 0, n)
+	now := time.Now()
+	for i := 0; i < n; i++ {
+		payload := fmt.Sprintf(
+			`{"single": [{"name": "bench", "valuetype": "gauge", "value": %d,
+			  "labels": {"series": "%d"}}]}`, i, i,
+		)
+		h, err := newHekaSampleScalar([]byte(payload), nil, time.Hour, now, now)
+		if err != nil {
+			b.Fatal(err)
+		}
+		hsamples = append(hsamples, h...)
+	}
+	return hsamples
+}
+
+// freshSample copies h the way decoding it again would, so benchmarks can
+// ingest it over and over without touching the sample the store holds.
+func freshSample(h *hekaSample) *hekaSample {
+	c := *h
+	m := *h.single
+	c.single = &m
+	return &c
+}
+
+func TestStoreKeyCollision(t *testing.T) {
+	s := newSampleStore(1)
+	now := time.Now()
+	hsamples, err := newHekaSampleScalar([]byte(`{"single": [
+	  {"name": "a", "valuetype": "gauge", "value": 1},
+	  {"name": "b", "valuetype": "gauge", "value": 2}
+	]}`), nil, time.Minute, now, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// pretend a and b hash alike
+	a, b := hsamples[0], hsamples[1]
+	a.key, b.key = 1, 1
+
+	if _, err := s.put(a); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.get(b); err != errKeyCollision {
+		t.Errorf("expected get to report the collision, got %v", err)
+	}
+	if _, err := s.put(b); err != errKeyCollision {
+		t.Errorf("expected put to refuse the collision, got %v", err)
+	}
+	if h, err := s.get(a); err != nil || h != a {
+		t.Errorf("expected a to stay stored, got %v, %v", h, err)
+	}
+}
+
+// BenchmarkIngestDuringScrape measures ingest throughput while scrapes run
+// back to back against the same store.
+func BenchmarkIngestDuringScrape(b *testing.B) {
+	const series = 20000
+
+	for _, shards := range []int{1, 32} {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			hsamples := storeBenchSamples(b, series)
+			config := new(PromOut).ConfigStruct().(*PromOutConfig)
+			config.StoreShards = shards
+			p := new(PromOut)
+			if err := p.setup(config); err != nil {
+				b.Fatal(err)
+			}
+			p.ingest(hsamples)
+
+			done := make(chan struct{})
+			var wg sync.WaitGroup
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					select {
+					case <-done:
+						return
+					default:
+					}
+					ch := make(chan prometheus.Metric, 1024)
+					go func() {
+						p.Collect(ch)
+						close(ch)
+					}()
+					for range ch {
+					}
+				}
+			}()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				p.ingest([]*hekaSample{freshSample(hsamples[i%series])})
+			}
+			b.StopTimer()
+			close(done)
+			wg.Wait()
+		})
+	}
+}
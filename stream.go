@@ -0,0 +1,189 @@
+package prometheus
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// writeStreamedExposition writes the default registry followed by the stored
+// samples, the latter straight from the store without building a
+// prometheus.Metric for each of them.
+func (p *PromOut) writeStreamedExposition(w io.Writer) error {
+	if err := writeExposition(w); err != nil {
+		return err
+	}
+	return p.writeSamples(w)
+}
+
+// writeSamples writes the stored samples in the text exposition format, one
+// family at a time in name order. Only pointers to the samples are gathered
+// up front, the text is produced while walking them.
+func (p *PromOut) writeSamples(w io.Writer) error {
+	now := time.Now()
+	defer func() {
+		p.scrapeDuration.Observe(time.Since(now).Seconds())
+	}()
+
+	byName := make(map[string][]*hekaSample)
+	p.samples.each(func(h *hekaSample) {
+		if now.After(h.expires) {
+			return
+		}
+		name, _, _ := sampleFamily(h)
+		byName[name] = append(byName[name], h)
+	})
+
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	bw := bufio.NewWriter(w)
+	for _, name := range names {
+		writeFamily(bw, byName[name])
+	}
+	return bw.Flush()
+}
+
+// writeFamily writes the samples of one family, followed by the _created
+// family of any counters among them that have been reset.
+func writeFamily(w *bufio.Writer, samples []*hekaSample) {
+	name, help, kind := sampleFamily(samples[0])
+	writeHeader(w, name, help, kind)
+
+	var created []*hekaSample
+	for _, h := range samples {
+		labels := sampleLabels(h)
+		switch {
+		case h.single != nil:
+			writeSeries(w, name, labels, "", "", h.single.Value)
+			if h.createdDesc != nil {
+				created = append(created, h)
+			}
+
+		case h.hist != nil:
+			bounds := make([]float64, 0, len(h.hist._buckets))
+			for b := range h.hist._buckets {
+				// the +Inf bucket is always written from the count
+				if !math.IsInf(b, 1) {
+					bounds = append(bounds, b)
+				}
+			}
+			sort.Float64s(bounds)
+			for _, b := range bounds {
+				writeSeries(w, name+"_bucket", labels, "le", formatFloat(b),
+					float64(h.hist._buckets[b]))
+			}
+			writeSeries(w, name+"_bucket", labels, "le", "+Inf",
+				float64(h.hist.Count))
+			writeSeries(w, name+"_sum", labels, "", "", h.hist.Sum)
+			writeSeries(w, name+"_count", labels, "", "", float64(h.hist.Count))
+
+		case h.summ != nil:
+			quantiles := make([]float64, 0, len(h.summ._quantiles))
+			for q := range h.summ._quantiles {
+				quantiles = append(quantiles, q)
+			}
+			sort.Float64s(quantiles)
+			for _, q := range quantiles {
+				writeSeries(w, name, labels, "quantile", formatFloat(q),
+					h.summ._quantiles[q])
+			}
+			writeSeries(w, name+"_sum", labels, "", "", h.summ.Sum)
+			writeSeries(w, name+"_count", labels, "", "", float64(h.summ.Count))
+		}
+	}
+
+	if len(created) == 0 {
+		return
+	}
+	writeHeader(w, name+"_created", help, "gauge")
+	for _, h := range created {
+		writeSeries(w, name+"_created", sampleLabels(h), "", "",
+			float64(h.created.UnixNano())/1e9)
+	}
+}
+
+func writeHeader(w *bufio.Writer, name, help, kind string) {
+	if help != "" {
+		w.WriteString("# HELP ")
+		w.WriteString(name)
+		w.WriteByte(' ')
+		helpEscaper.WriteString(w, help)
+		w.WriteByte('\n')
+	}
+	w.WriteString("# TYPE ")
+	w.WriteString(name)
+	w.WriteByte(' ')
+	w.WriteString(kind)
+	w.WriteByte('\n')
+}
+
+// writeSeries writes a single line of the exposition. extraName and
+// extraValue add the le or quantile label when set.
+func writeSeries(w *bufio.Writer, name string, labels map[string]string,
+	extraName, extraValue string, value float64) {
+
+	w.WriteString(name)
+	if len(labels) > 0 || extraName != "" {
+		var buf [8]string
+		names := buf[:0]
+		for k := range labels {
+			names = append(names, k)
+		}
+		sortNames(names)
+
+		w.WriteByte('{')
+		for i, k := range names {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			writeLabel(w, k, labels[k])
+		}
+		if extraName != "" {
+			if len(names) > 0 {
+				w.WriteByte(',')
+			}
+			writeLabel(w, extraName, extraValue)
+		}
+		w.WriteByte('}')
+	}
+	var buf [32]byte
+	w.WriteByte(' ')
+	w.Write(appendFloat(buf[:0], value))
+	w.WriteByte('\n')
+}
+
+func writeLabel(w *bufio.Writer, name, value string) {
+	w.WriteString(name)
+	w.WriteString(`="`)
+	labelEscaper.WriteString(w, value)
+	w.WriteByte('"')
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func appendFloat(b []byte, f float64) []byte {
+	switch {
+	case math.IsInf(f, 1):
+		return append(b, "+Inf"...)
+	case math.IsInf(f, -1):
+		return append(b, "-Inf"...)
+	case math.IsNaN(f):
+		return append(b, "NaN"...)
+	}
+	return strconv.AppendFloat(b, f, 'g', -1, 64)
+}
+
+func formatFloat(f float64) string {
+	return string(appendFloat(nil, f))
+}
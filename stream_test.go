@@ -0,0 +1,148 @@
+package prometheus
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+func TestWriteSamples(t *testing.T) {
+	config := new(PromOut).ConfigStruct().(*PromOutConfig)
+	config.EmitCreated = true
+	p := newTestPromOut(t, config)
+
+	now := time.Now()
+	for _, payload := range []string{`
+{
+  "single": [
+    {"name": "requests", "valuetype": "counter", "help": "requests\nserved", "value": 10,
+     "labels": {"host": "web1", "path": "/a\"b\\c"}},
+    {"name": "inflight", "valuetype": "gauge", "value": 3},
+    {"name": "requests", "valuetype": "counter", "help": "requests\nserved", "value": 7,
+     "labels": {"host": "web2", "path": "/"}}
+  ],
+  "histogram": [
+    {"name": "latency", "help": "request latency", "count": 3, "sum": 1.5,
+     "labels": {"host": "web1"}, "buckets": {"0.1": 1, "1": 2, "+Inf": 3}}
+  ],
+  "summary": [
+    {"name": "size", "help": "response size", "count": 2, "sum": 300,
+     "quantiles": {"0.5": 100, "0.9": 200}}
+  ]
+}`,
+		// resets the first counter
+		`{"single": [{"name": "requests", "valuetype": "counter", "help": "requests\nserved",
+		  "value": 1, "labels": {"host": "web1", "path": "/a\"b\\c"}}]}`,
+	} {
+		hsamples, err := newHekaSampleScalar([]byte(payload), nil, time.Minute, now, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.ingest(hsamples)
+	}
+
+	var buf bytes.Buffer
+	if err := p.writeSamples(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(buf.String(), `le="+Inf"`); n != 1 {
+		t.Errorf("expected a single +Inf bucket, got %d in\n%s", n, buf.String())
+	}
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(&buf)
+	if err != nil {
+		t.Fatalf("%v\n%s", err, buf.String())
+	}
+
+	for name, kind := range map[string]dto.MetricType{
+		"requests":         dto.MetricType_COUNTER,
+		"requests_created": dto.MetricType_GAUGE,
+		"inflight":         dto.MetricType_GAUGE,
+		"latency":          dto.MetricType_HISTOGRAM,
+		"size":             dto.MetricType_SUMMARY,
+	} {
+		f, ok := families[name]
+		if !ok {
+			t.Errorf("family %s missing", name)
+			continue
+		}
+		if f.GetType() != kind {
+			t.Errorf("family %s has type %v, expected %v", name, f.GetType(), kind)
+		}
+	}
+
+	requests := families["requests"]
+	if requests.GetHelp() != "requests\nserved" || len(requests.Metric) != 2 {
+		t.Errorf("requests family mangled: %v", requests)
+	}
+	for _, m := range requests.Metric {
+		for _, l := range m.Label {
+			if l.GetName() == "path" && l.GetValue() == `/a"b\c` &&
+				m.GetCounter().GetValue() != 1 {
+				t.Errorf("escaped series has the wrong value: %v", m)
+			}
+		}
+	}
+
+	h := families["latency"].Metric[0].GetHistogram()
+	if h.GetSampleCount() != 3 || h.GetSampleSum() != 1.5 || len(h.Bucket) != 3 ||
+		h.Bucket[0].GetUpperBound() != 0.1 || h.Bucket[1].GetCumulativeCount() != 2 {
+		t.Errorf("histogram mangled: %v", h)
+	}
+	s := families["size"].Metric[0].GetSummary()
+	if s.GetSampleCount() != 2 || len(s.Quantile) != 2 || s.Quantile[1].GetValue() != 200 {
+		t.Errorf("summary mangled: %v", s)
+	}
+}
+
+// BenchmarkScrape compares a scrape through the client library with a
+// streamed one over the same store.
+func BenchmarkScrape(b *testing.B) {
+	b.Run("collect", func(b *testing.B) {
+		hsamples := storeBenchSamples(b, 20000)
+		p := new(PromOut)
+		if err := p.setup(p.ConfigStruct().(*PromOutConfig)); err != nil {
+			b.Fatal(err)
+		}
+		p.ingest(hsamples)
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(p)
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			families, err := registry.Gather()
+			if err != nil {
+				b.Fatal(err)
+			}
+			for _, f := range families {
+				expfmt.MetricFamilyToText(ioutil.Discard, f)
+			}
+		}
+	})
+
+	b.Run("stream", func(b *testing.B) {
+		hsamples := storeBenchSamples(b, 20000)
+		config := new(PromOut).ConfigStruct().(*PromOutConfig)
+		config.StreamExposition = true
+		p := new(PromOut)
+		if err := p.setup(config); err != nil {
+			b.Fatal(err)
+		}
+		p.ingest(hsamples)
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if err := p.writeSamples(ioutil.Discard); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}